@@ -8,7 +8,6 @@ import (
 	"os"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/sirupsen/logrus"
 	kwhhttp "github.com/slok/kubewebhook/v2/pkg/http"
@@ -17,13 +16,7 @@ import (
 	kwhmutating "github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
 )
 
-func annotatePodMutator(_ context.Context, _ *kwhmodel.AdmissionReview, obj metav1.Object) (*kwhmutating.MutatorResult, error) {
-	pod, ok := obj.(*corev1.Pod)
-	if !ok {
-		// If not a pod just continue the mutation chain(if there is one) and don't do nothing.
-		return &kwhmutating.MutatorResult{}, nil
-	}
-
+func annotatePodMutator(_ context.Context, _ *kwhmodel.AdmissionReview, pod *corev1.Pod) (*kwhmutating.MutatorResult, error) {
 	// Mutate our object with the required annotations.
 	if pod.Annotations == nil {
 		pod.Annotations = make(map[string]string)
@@ -59,16 +52,15 @@ func main() {
 
 	cfg := initFlags()
 
-	// Create our mutator
-	mt := kwhmutating.MutatorFunc(annotatePodMutator)
+	// Create our typed mutator.
+	mt := kwhmutating.TypedMutatorFunc[*corev1.Pod](annotatePodMutator)
 
-	mcfg := kwhmutating.WebhookConfig{
+	mcfg := kwhmutating.TypedWebhookConfig[*corev1.Pod]{
 		ID:      "podAnnotate",
-		Obj:     &corev1.Pod{},
 		Mutator: mt,
 		Logger:  logger,
 	}
-	wh, err := kwhmutating.NewWebhook(mcfg)
+	wh, err := kwhmutating.NewTypedWebhook(mcfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error creating webhook: %s", err)
 		os.Exit(1)
@@ -10,6 +10,7 @@ import (
 
 	whhttp "github.com/slok/kubewebhook/v2/pkg/http"
 	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
 )
@@ -86,3 +87,35 @@ func ExampleHandlerFor_serveMultipleWebhooks() {
 	mux.Handle("/mutate-pod", mwhHandler)
 	_ = http.ListenAndServeTLS(":8080", "file.cert", "file.key", mux)
 }
+
+// ServeMultipleWebhooksWithRouter shows how to serve multiple webhooks in the same server
+// using the router, that will mount each webhook on a path based on its ID and kind.
+func ExampleRouterFor_serveMultipleWebhooks() {
+	// Create a stub validator and a stub mutator.
+	v := validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*validating.ValidatorResult, error) {
+		return &validating.ValidatorResult{Valid: true}, nil
+	})
+	m := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		return &mutating.MutatorResult{}, nil
+	})
+
+	// Create webhooks (don't check error).
+	vwh, _ := validating.NewWebhook(validating.WebhookConfig{
+		ID:        "pod-validator",
+		Obj:       &corev1.Pod{},
+		Validator: v,
+	})
+	mwh, _ := mutating.NewWebhook(mutating.WebhookConfig{
+		ID:      "pod-mutator",
+		Obj:     &corev1.Pod{},
+		Mutator: m,
+	})
+
+	// Create the router, the webhooks will be served on:
+	// - `/webhooks/validating/pod-validator`.
+	// - `/webhooks/mutating/pod-mutator`.
+	router, _ := whhttp.RouterFor(whhttp.RouterConfig{
+		Webhooks: []webhook.Webhook{vwh, mwh},
+	})
+	_ = http.ListenAndServeTLS(":8080", "file.cert", "file.key", router)
+}
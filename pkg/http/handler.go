@@ -3,15 +3,17 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -45,11 +47,67 @@ func MustHandlerFor(config HandlerConfig) http.Handler {
 	return h
 }
 
+// FailurePolicy is the policy the handler will apply when the webhook can't review
+// an admission request correctly.
+type FailurePolicy string
+
+const (
+	// FailurePolicyPassthrough will return the error to the apiserver as an HTTP 500, so the
+	// webhook registration `failurePolicy` will make the final decision. Typed errors (e.g
+	// `webhook.AdmissionError`) are meant to be returned to the user, so they will deny the
+	// request with their status.
+	FailurePolicyPassthrough FailurePolicy = "passthrough"
+	// FailurePolicyFailOpen will allow the admission request and return the failure message as
+	// a warning.
+	FailurePolicyFailOpen FailurePolicy = "fail-open"
+	// FailurePolicyFailClosed will deny the admission request with the failure message.
+	FailurePolicyFailClosed FailurePolicy = "fail-closed"
+)
+
+// DefaultFailureMessage is the message used when failure policies allow or deny a failed
+// admission review, and the user didn't set a message.
+const DefaultFailureMessage = "the admission webhook could not review the request"
+
+// DefaultTimeoutSafetyMargin is the default time subtracted from the apiserver timeout to set
+// the admission review deadline.
+const DefaultTimeoutSafetyMargin = 500 * time.Millisecond
+
+// Failure reasons used when measuring the applied failure policies.
+const (
+	failureReasonReviewError = "review-error"
+	failureReasonTimeout     = "timeout"
+	failureReasonLoadShed    = "load-shed"
+	failureReasonPanic       = "panic"
+)
+
 // HandlerConfig is the configuration for the webhook handlers.
 type HandlerConfig struct {
 	Webhook webhook.Webhook
 	Logger  log.Logger
 	Tracer  tracing.Tracer
+	// MetricsRecorder will measure the handler operations (e.g the applied failure policies).
+	MetricsRecorder webhook.MetricsRecorder
+	// FailurePolicy is the policy applied when the webhook review fails, by default
+	// `FailurePolicyPassthrough`.
+	FailurePolicy FailurePolicy
+	// FailureMessage is the message used as the warning or denial message when the failure
+	// policy allows or denies the request, by default `DefaultFailureMessage`. The original
+	// error will only be logged, it will not be returned to the user.
+	FailureMessage string
+	// TimeoutSafetyMargin is the time subtracted from the apiserver `timeout` query parameter
+	// to set the admission review deadline, so the handler answers before the apiserver times out.
+	// By default `DefaultTimeoutSafetyMargin`.
+	TimeoutSafetyMargin time.Duration
+	// TimeoutPolicy is the policy applied when the admission review deadline is reached, by
+	// default the same as the `FailurePolicy`.
+	TimeoutPolicy FailurePolicy
+	// MaxInflight is the max number of admission reviews that the webhook will handle
+	// concurrently, by default `0` (unlimited).
+	MaxInflight int
+	// MaxQueue is the max number of admission reviews that will wait for an execution slot when
+	// `MaxInflight` has been reached. The reviews that don't fit on the queue, or reach their deadline
+	// while waiting, will be shed using the `FailurePolicy`. By default `0` (no queue).
+	MaxQueue int
 }
 
 func (c *HandlerConfig) defaults() error {
@@ -67,6 +125,46 @@ func (c *HandlerConfig) defaults() error {
 	}
 	c.Tracer = c.Tracer.WithValues(map[string]interface{}{"svc": "http.Handler"})
 
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	switch c.FailurePolicy {
+	case "":
+		c.FailurePolicy = FailurePolicyPassthrough
+	case FailurePolicyPassthrough, FailurePolicyFailOpen, FailurePolicyFailClosed:
+	default:
+		return fmt.Errorf("unknown failure policy %q", c.FailurePolicy)
+	}
+
+	if c.FailureMessage == "" {
+		c.FailureMessage = DefaultFailureMessage
+	}
+
+	if c.TimeoutSafetyMargin <= 0 {
+		c.TimeoutSafetyMargin = DefaultTimeoutSafetyMargin
+	}
+
+	switch c.TimeoutPolicy {
+	case "":
+		c.TimeoutPolicy = c.FailurePolicy
+	case FailurePolicyPassthrough, FailurePolicyFailOpen, FailurePolicyFailClosed:
+	default:
+		return fmt.Errorf("unknown timeout policy %q", c.TimeoutPolicy)
+	}
+
+	if c.MaxInflight < 0 {
+		return fmt.Errorf("max inflight can't be negative")
+	}
+
+	if c.MaxQueue < 0 {
+		return fmt.Errorf("max queue can't be negative")
+	}
+
+	if c.MaxQueue > 0 && c.MaxInflight == 0 {
+		return fmt.Errorf("max queue requires max inflight")
+	}
+
 	return nil
 }
 
@@ -78,19 +176,37 @@ func HandlerFor(config HandlerConfig) (http.Handler, error) {
 		return nil, fmt.Errorf("handler invalid configuration: %w", err)
 	}
 
+	metricsRec := webhook.NewFullMetricsRecorder(config.MetricsRecorder)
+	var lim *limiter
+	if config.MaxInflight > 0 {
+		lim = newLimiter(config.MaxInflight, config.MaxQueue, config.Webhook, metricsRec)
+	}
+
 	h := config.Tracer.TraceHTTPHandler("webhookHTTPHandler", handler{
-		webhook: config.Webhook,
-		logger:  config.Logger,
-		tracer:  config.Tracer,
+		webhook:        config.Webhook,
+		logger:         config.Logger,
+		tracer:         config.Tracer,
+		metricsRec:     metricsRec,
+		failurePolicy:  config.FailurePolicy,
+		failureMessage: config.FailureMessage,
+		timeoutMargin:  config.TimeoutSafetyMargin,
+		timeoutPolicy:  config.TimeoutPolicy,
+		limiter:        lim,
 	})
 
 	return h, nil
 }
 
 type handler struct {
-	webhook webhook.Webhook
-	logger  log.Logger
-	tracer  tracing.Tracer
+	webhook        webhook.Webhook
+	logger         log.Logger
+	tracer         tracing.Tracer
+	metricsRec     webhook.FailurePolicyMetricsRecorder
+	failurePolicy  FailurePolicy
+	failureMessage string
+	timeoutMargin  time.Duration
+	timeoutPolicy  FailurePolicy
+	limiter        *limiter
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +230,7 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ar, err := h.requestBodyToModelReview(body)
+	ar, err := requestBodyToModelReview(body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		h.logger.Errorf("could not parse body to model review: %s", err)
@@ -141,30 +257,48 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// |                        | HTTP Code             | status.Code | status.Status | status.Message |
 	// |------------------------|-----------------------| ------------|---------------|----------------|
 	// | Validating Allowed     | 200                   | -           | -             | -              |
-	// | Validating not allowed | 200                   | 400         | Failure       | Custom message |
+	// | Validating not allowed | 200                   | 400/Custom  | Failure       | Custom message |
 	// | Mutating mutation      | 200                   | -           | -             | -              |
 	// | Mutating no mutation   | 200                   | -           | -             | -              |
-	// | Err                    | 500                   | -           | Failure       | Err string     |
-	admissionResp, err := h.webhook.Review(ctx, *ar)
-	if err != nil {
-		logger.Errorf("Admission review error: %s", err)
-
-		errResp, err := h.errorToJSON(*ar, err)
-		if err != nil {
-			msg := fmt.Sprintf("could not marshall status error on admission response: %v", err)
-			http.Error(w, msg, http.StatusInternalServerError)
-			logger.Errorf(msg)
+	// | Mutating denied        | 200                   | 400/Custom  | Failure       | Custom message |
+	// | Err (passthrough)      | 500                   | -           | Failure       | Err string     |
+	// | Typed err (passthrough)| 200                   | Typed       | Failure       | Typed msg      |
+	// | Err (fail open)        | 200                   | -           | -             | -              |
+	// | Err (fail closed)      | 200                   | 500/Typed   | Failure       | Failure msg    |
+	//
+	// Typed errors (`webhook.AdmissionError`) will set the status code, reason and details from
+	// the error, the rest of errors (including Kubernetes API errors) are not returned to the user.
+	// The apiserver ignores the body of the not 200 responses, so typed errors are always returned
+	// as denied admission reviews.
+	//
+	// If the review deadline is reached, the timeout policy will be applied in the same way,
+	// and if the review is shed by the concurrency limit or panics, the failure policy will be applied.
+	reviewCtx, cancel := h.reviewContext(ctx, r)
+	defer cancel()
+
+	// Limit the concurrency if required, the reviews that can't be handled will be shed.
+	// The execution slot is released when the webhook review ends, not when the handler answers,
+	// so the reviews that outlive their deadline are still limited.
+	reviewDone := func() {}
+	if h.limiter != nil {
+		if !h.limiter.acquire(reviewCtx) {
+			logger.Warningf("Admission review shed, concurrency limit reached")
+			h.handleFailure(ctx, w, *ar, fmt.Errorf("admission review shed, concurrency limit reached"), h.failurePolicy, failureReasonLoadShed)
 			return
 		}
+		reviewDone = func() { h.limiter.release(ctx) }
+	}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		if _, err := w.Write(errResp); err != nil {
-			msg := fmt.Sprintf("could not write response: %v", err)
-			http.Error(w, msg, http.StatusInternalServerError)
-			logger.Errorf(msg)
-			return
+	admissionResp, failureReason, err := h.review(reviewCtx, *ar, reviewDone)
+	if err != nil {
+		switch failureReason {
+		case failureReasonTimeout:
+			logger.Errorf("Admission review deadline reached: %s", err)
+			h.handleFailure(ctx, w, *ar, err, h.timeoutPolicy, failureReason)
+		default:
+			logger.Errorf("Admission review error: %s", err)
+			h.handleFailure(ctx, w, *ar, err, h.failurePolicy, failureReason)
 		}
-
 		return
 	}
 
@@ -205,7 +339,170 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"duration": time.Since(t0),
 	}).Infof("Admission review request handled")
 }
-func (h handler) requestBodyToModelReview(body []byte) (*model.AdmissionReview, error) {
+
+// reviewContext returns the context used for the admission review, if the apiserver sent
+// the timeout of the request, the context will have a deadline before the apiserver one.
+func (h handler) reviewContext(ctx context.Context, r *http.Request) (context.Context, context.CancelFunc) {
+	timeoutQ := r.URL.Query().Get("timeout")
+	if timeoutQ == "" {
+		return context.WithCancel(ctx)
+	}
+
+	timeout, err := time.ParseDuration(timeoutQ)
+	if err != nil || timeout <= 0 {
+		h.logger.WithCtxValues(ctx).Warningf("Ignoring invalid apiserver timeout %q", timeoutQ)
+		return context.WithCancel(ctx)
+	}
+
+	// If the margin is bigger than the timeout, use half of the timeout, is better than nothing.
+	reviewTimeout := timeout - h.timeoutMargin
+	if reviewTimeout <= 0 {
+		reviewTimeout = timeout / 2
+	}
+
+	return context.WithTimeout(ctx, reviewTimeout)
+}
+
+type reviewResult struct {
+	resp          model.AdmissionResponse
+	err           error
+	failureReason string
+}
+
+// review will execute the webhook review. If the context has a deadline, the review will be
+// executed in the background and it will return with timeout if the deadline is reached
+// before the webhook review ends.
+// done is called when the webhook review ends, even if it ends after the deadline.
+// In case of failure, it will return the reason of the failure.
+func (h handler) review(ctx context.Context, ar model.AdmissionReview, done func()) (resp model.AdmissionResponse, failureReason string, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		res := h.safeReview(ctx, ar)
+		done()
+		return res.resp, res.failureReason, res.err
+	}
+
+	resC := make(chan reviewResult, 1)
+	go func() {
+		res := h.safeReview(ctx, ar)
+		done()
+		resC <- res
+	}()
+
+	select {
+	case res := <-resC:
+		// The webhook could end due to the deadline.
+		if res.err != nil && res.failureReason == failureReasonReviewError && ctx.Err() != nil {
+			return nil, failureReasonTimeout, res.err
+		}
+
+		return res.resp, res.failureReason, res.err
+	case <-ctx.Done():
+		return nil, failureReasonTimeout, ctx.Err()
+	}
+}
+
+// safeReview executes the webhook review recovering from panics, so a panic on the
+// webhook is handled as a failed review instead of breaking the connection with the apiserver.
+// The review is traced, and the failed reviews (including panics) will end the trace with the error.
+func (h handler) safeReview(ctx context.Context, ar model.AdmissionReview) (res reviewResult) {
+	ctx = h.tracer.NewTrace(ctx, "webhookHTTPHandler.review")
+	defer func() {
+		if p := recover(); p != nil {
+			h.logger.WithCtxValues(ctx).Errorf("Admission review panic recovered: %v\n%s", p, debug.Stack())
+			res = reviewResult{
+				err:           fmt.Errorf("admission review panic: %v", p),
+				failureReason: failureReasonPanic,
+			}
+		}
+		h.tracer.EndTrace(ctx, res.err)
+	}()
+
+	resp, err := h.webhook.Review(ctx, ar)
+	if err != nil {
+		return reviewResult{err: err, failureReason: failureReasonReviewError}
+	}
+
+	return reviewResult{resp: resp}
+}
+
+// handleFailure will write the response of a failed admission review based on the
+// received failure policy.
+func (h handler) handleFailure(ctx context.Context, w http.ResponseWriter, review model.AdmissionReview, err error, policy FailurePolicy, reason string) {
+	logger := h.logger.WithCtxValues(ctx).WithValues(log.Kv{"failure-policy": policy, "failure-reason": reason})
+	h.metricsRec.MeasureFailurePolicyOp(ctx, webhook.MeasureFailurePolicyOpData{
+		WebhookID:              h.webhook.ID(),
+		WebhookType:            string(h.webhook.Kind()),
+		AdmissionReviewVersion: string(review.Version),
+		FailurePolicy:          string(policy),
+		Reason:                 reason,
+	})
+
+	var (
+		resp     []byte
+		respErr  error
+		httpCode = http.StatusOK
+	)
+	switch policy {
+	case FailurePolicyFailOpen:
+		logger.Warningf("Failure policy applied, admission review allowed")
+		resp, respErr = h.failOpenToJSON(ctx, review)
+	case FailurePolicyFailClosed:
+		logger.Warningf("Failure policy applied, admission review denied")
+		// Typed errors are meant to be returned to the user, so we deny with them, the rest
+		// are not returned to the user.
+		status, ok := errorToStatus(err)
+		if !ok {
+			status = metav1.Status{
+				Message: h.failureMessage,
+				Status:  metav1.StatusFailure,
+				Reason:  metav1.StatusReasonInternalError,
+				Code:    http.StatusInternalServerError,
+			}
+		}
+		resp, respErr = statusToJSON(review, status)
+	default:
+		// The apiserver ignores the body of the not 200 responses, typed errors are meant to be returned
+		// to the user, so we deny with them.
+		if status, ok := errorToStatus(err); ok {
+			resp, respErr = statusToJSON(review, status)
+			break
+		}
+		httpCode = http.StatusInternalServerError
+		resp, respErr = h.errorToJSON(review, err)
+	}
+	if respErr != nil {
+		msg := fmt.Sprintf("could not marshall status error on admission response: %v", respErr)
+		http.Error(w, msg, http.StatusInternalServerError)
+		logger.Errorf(msg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpCode)
+	if _, err := w.Write(resp); err != nil {
+		logger.Errorf("could not write response: %v", err)
+		return
+	}
+}
+
+// failOpenToJSON returns an allowed admission review response with the failure message as a warning.
+func (h handler) failOpenToJSON(ctx context.Context, review model.AdmissionReview) ([]byte, error) {
+	warnings := []string{h.failureMessage}
+	if h.webhook.Kind() == model.WebhookKindMutating {
+		return h.mutatingModelResponseToJSON(ctx, review, &model.MutatingAdmissionResponse{
+			ID:       review.ID,
+			Warnings: warnings,
+		})
+	}
+
+	return h.validatingModelResponseToJSON(ctx, review, &model.ValidatingAdmissionResponse{
+		ID:       review.ID,
+		Allowed:  true,
+		Warnings: warnings,
+	})
+}
+
+func requestBodyToModelReview(body []byte) (*model.AdmissionReview, error) {
 	kubeReview, _, err := admissionReviewDeserializer.Decode(body, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode the admission review from the request: %w", err)
@@ -238,11 +535,7 @@ func (h handler) validatingModelResponseToJSON(ctx context.Context, review model
 	// Set the satus code and result based on the validation result.
 	var resultStatus *metav1.Status
 	if !resp.Allowed {
-		resultStatus = &metav1.Status{
-			Message: resp.Message,
-			Status:  metav1.StatusFailure,
-			Code:    http.StatusBadRequest,
-		}
+		resultStatus = deniedStatus(resp.Message, resp.Code, resp.Reason, resp.Causes)
 	}
 
 	switch review.OriginalAdmissionReview.(type) {
@@ -250,6 +543,7 @@ func (h handler) validatingModelResponseToJSON(ctx context.Context, review model
 		if len(resp.Warnings) > 0 {
 			h.logger.WithCtxValues(ctx).Warningf("warnings used in a 'v1beta1' webhook")
 		}
+		h.logDroppedAuditAnnotations(ctx, resp.AuditAnnotations)
 
 		data, err := json.Marshal(admissionv1beta1.AdmissionReview{
 			TypeMeta: v1beta1AdmissionReviewTypeMeta,
@@ -265,10 +559,11 @@ func (h handler) validatingModelResponseToJSON(ctx context.Context, review model
 		data, err := json.Marshal(admissionv1.AdmissionReview{
 			TypeMeta: v1AdmissionReviewTypeMeta,
 			Response: &admissionv1.AdmissionResponse{
-				UID:      types.UID(review.ID),
-				Warnings: resp.Warnings,
-				Allowed:  resp.Allowed,
-				Result:   resultStatus,
+				UID:              types.UID(review.ID),
+				Warnings:         resp.Warnings,
+				AuditAnnotations: resp.AuditAnnotations,
+				Allowed:          resp.Allowed,
+				Result:           resultStatus,
 			},
 		})
 		return data, err
@@ -278,33 +573,61 @@ func (h handler) validatingModelResponseToJSON(ctx context.Context, review model
 }
 
 func (h handler) mutatingModelResponseToJSON(ctx context.Context, review model.AdmissionReview, resp *model.MutatingAdmissionResponse) (data []byte, err error) {
+	// Denied mutations don't have patch, only the status of the denial.
+	var resultStatus *metav1.Status
+	if resp.Denied {
+		resultStatus = deniedStatus(resp.Message, resp.Code, resp.Reason, nil)
+	}
+
 	switch review.OriginalAdmissionReview.(type) {
 	case *admissionv1beta1.AdmissionReview:
 		if len(resp.Warnings) > 0 {
 			h.logger.WithCtxValues(ctx).Warningf("warnings used in a 'v1beta1' webhook")
 		}
+		h.logDroppedAuditAnnotations(ctx, resp.AuditAnnotations)
+
+		arResp := &admissionv1beta1.AdmissionResponse{
+			UID:       types.UID(review.ID),
+			PatchType: v1beta1JSONPatchType,
+			Patch:     resp.JSONPatchPatch,
+			Allowed:   true,
+		}
+		if resp.Denied {
+			arResp = &admissionv1beta1.AdmissionResponse{
+				UID:     types.UID(review.ID),
+				Allowed: false,
+				Result:  resultStatus,
+			}
+		}
 
 		data, err := json.Marshal(admissionv1beta1.AdmissionReview{
 			TypeMeta: v1beta1AdmissionReviewTypeMeta,
-			Response: &admissionv1beta1.AdmissionResponse{
-				UID:       types.UID(review.ID),
-				PatchType: v1beta1JSONPatchType,
-				Patch:     resp.JSONPatchPatch,
-				Allowed:   true,
-			},
+			Response: arResp,
 		})
 		return data, err
 
 	case *admissionv1.AdmissionReview:
+		arResp := &admissionv1.AdmissionResponse{
+			UID:              types.UID(review.ID),
+			PatchType:        v1JSONPatchType,
+			Patch:            resp.JSONPatchPatch,
+			Allowed:          true,
+			Warnings:         resp.Warnings,
+			AuditAnnotations: resp.AuditAnnotations,
+		}
+		if resp.Denied {
+			arResp = &admissionv1.AdmissionResponse{
+				UID:              types.UID(review.ID),
+				Allowed:          false,
+				Result:           resultStatus,
+				Warnings:         resp.Warnings,
+				AuditAnnotations: resp.AuditAnnotations,
+			}
+		}
+
 		data, err := json.Marshal(admissionv1.AdmissionReview{
 			TypeMeta: v1AdmissionReviewTypeMeta,
-			Response: &admissionv1.AdmissionResponse{
-				UID:       types.UID(review.ID),
-				PatchType: v1JSONPatchType,
-				Patch:     resp.JSONPatchPatch,
-				Allowed:   true,
-				Warnings:  resp.Warnings,
-			},
+			Response: arResp,
 		})
 
 		return data, err
@@ -313,15 +636,75 @@ func (h handler) mutatingModelResponseToJSON(ctx context.Context, review model.A
 	return nil, fmt.Errorf("invalid admission response type")
 }
 
+// deniedStatus returns the status of a denied admission review, by default the code
+// will be 400 (Bad Request).
+func deniedStatus(message string, code int32, reason metav1.StatusReason, causes []metav1.StatusCause) *metav1.Status {
+	if code == 0 {
+		code = http.StatusBadRequest
+	}
+
+	status := &metav1.Status{
+		Message: message,
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  reason,
+	}
+	if len(causes) > 0 {
+		status.Details = &metav1.StatusDetails{Causes: causes}
+	}
+
+	return status
+}
+
+// logDroppedAuditAnnotations logs the audit annotations that are dropped because the webhook
+// is using 'v1beta1' admission reviews, so they are not lost.
+func (h handler) logDroppedAuditAnnotations(ctx context.Context, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	h.logger.WithCtxValues(ctx).WithValues(log.Kv{"audit-annotations": annotations}).Warningf("audit annotations used in a 'v1beta1' webhook, dropped")
+}
+
 func (h handler) errorToJSON(review model.AdmissionReview, err error) ([]byte, error) {
+	status, ok := errorToStatus(err)
+	if !ok {
+		status = metav1.Status{
+			Message: err.Error(),
+			Status:  metav1.StatusFailure,
+		}
+	}
+
+	return statusToJSON(review, status)
+}
+
+// errorToStatus returns the status of typed errors (`webhook.AdmissionError`), these errors can be wrapped.
+// If the error is not a typed error it will return false.
+//
+// Other errors with status, like Kubernetes API errors (e.g a not found error of a lookup made by a
+// validator), are not typed errors, the users must not receive them.
+func errorToStatus(err error) (metav1.Status, bool) {
+	var admissionErr *webhook.AdmissionError
+	if !errors.As(err, &admissionErr) {
+		return metav1.Status{}, false
+	}
+
+	status := admissionErr.Status()
+	if status.Status == "" {
+		status.Status = metav1.StatusFailure
+	}
+
+	return status, true
+}
+
+// statusToJSON returns a not allowed admission review response with the received status
+// in the same admission review version the review was received.
+func statusToJSON(review model.AdmissionReview, status metav1.Status) ([]byte, error) {
 	switch review.OriginalAdmissionReview.(type) {
 	case *admissionv1beta1.AdmissionReview:
 		r := &admissionv1beta1.AdmissionResponse{
-			UID: types.UID(review.ID),
-			Result: &metav1.Status{
-				Message: err.Error(),
-				Status:  metav1.StatusFailure,
-			},
+			UID:    types.UID(review.ID),
+			Result: &status,
 		}
 
 		return json.Marshal(admissionv1beta1.AdmissionReview{
@@ -330,11 +713,8 @@ func (h handler) errorToJSON(review model.AdmissionReview, err error) ([]byte, e
 		})
 	case *admissionv1.AdmissionReview:
 		r := &admissionv1.AdmissionResponse{
-			UID: types.UID(review.ID),
-			Result: &metav1.Status{
-				Message: err.Error(),
-				Status:  metav1.StatusFailure,
-			},
+			UID:    types.UID(review.ID),
+			Result: &status,
 		}
 
 		return json.Marshal(admissionv1.AdmissionReview{
@@ -365,7 +745,7 @@ func configReader(req *http.Request) ([]byte, error) {
 		return nil, err
 	}
 	if lr.N <= 0 {
-		return nil, errors.NewRequestEntityTooLargeError(fmt.Sprintf("limit is %d", MaxRequestBodyBytes))
+		return nil, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf("limit is %d", MaxRequestBodyBytes))
 	}
 	return data, nil
 }
@@ -2,9 +2,12 @@ package http_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -12,14 +15,19 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	kubewebhookhttp "github.com/slok/kubewebhook/v2/pkg/http"
 	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
 )
 
@@ -203,6 +211,132 @@ func TestDefaultWebhookFlow(t *testing.T) {
 			expCode: 200,
 		},
 
+		"A correct validation admission v1 webhook that doesn't allow with custom status should not fail.": {
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.ValidatingAdmissionResponse{
+					ID:      "1234567890",
+					Allowed: false,
+					Message: "invalid replicas",
+					Code:    422,
+					Reason:  metav1.StatusReasonInvalid,
+					Causes: []metav1.StatusCause{
+						{Type: metav1.CauseTypeFieldValueInvalid, Message: "Invalid value: 0: must be positive", Field: "spec.replicas"},
+					},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"invalid replicas","reason":"Invalid","details":{"causes":[{"reason":"FieldValueInvalid","message":"Invalid value: 0: must be positive","field":"spec.replicas"}]},"code":422}}}`,
+			expCode: 200,
+		},
+
+		"A correct validation admission v1beta1 webhook that doesn't allow with custom status should not fail.": {
+			body: getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.ValidatingAdmissionResponse{
+					ID:      "1234567890",
+					Allowed: false,
+					Message: "invalid replicas",
+					Code:    422,
+					Reason:  metav1.StatusReasonInvalid,
+					Causes: []metav1.StatusCause{
+						{Type: metav1.CauseTypeFieldValueInvalid, Message: "Invalid value: 0: must be positive", Field: "spec.replicas"},
+					},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"invalid replicas","reason":"Invalid","details":{"causes":[{"reason":"FieldValueInvalid","message":"Invalid value: 0: must be positive","field":"spec.replicas"}]},"code":422}}}`,
+			expCode: 200,
+		},
+
+		"A correct validation admission v1 webhook with audit annotations should not fail.": {
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.ValidatingAdmissionResponse{
+					ID:               "1234567890",
+					Allowed:          true,
+					AuditAnnotations: map[string]string{"reason": "allowed-exception"},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"auditAnnotations":{"reason":"allowed-exception"}}}`,
+			expCode: 200,
+		},
+
+		"A correct validation admission v1beta1 webhook with audit annotations should drop them.": {
+			body: getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.ValidatingAdmissionResponse{
+					ID:               "1234567890",
+					Allowed:          true,
+					AuditAnnotations: map[string]string{"reason": "allowed-exception"},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":true}}`,
+			expCode: 200,
+		},
+
+		"A correct mutating admission v1 webhook with audit annotations should not fail.": {
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.MutatingAdmissionResponse{
+					ID:               "1234567890",
+					JSONPatchPatch:   []byte(`{"something": something}`),
+					AuditAnnotations: map[string]string{"mutated-by": "test"},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"patch":"eyJzb21ldGhpbmciOiBzb21ldGhpbmd9","patchType":"JSONPatch","auditAnnotations":{"mutated-by":"test"}}}`,
+			expCode: 200,
+		},
+
+		"A correct mutating admission v1beta1 webhook with audit annotations should drop them.": {
+			body: getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.MutatingAdmissionResponse{
+					ID:               "1234567890",
+					JSONPatchPatch:   []byte(`{"something": something}`),
+					AuditAnnotations: map[string]string{"mutated-by": "test"},
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":true,"patch":"eyJzb21ldGhpbmciOiBzb21ldGhpbmd9","patchType":"JSONPatch"}}`,
+			expCode: 200,
+		},
+
+		"A correct mutating admission v1 webhook that denies should not fail.": {
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.MutatingAdmissionResponse{
+					ID:             "1234567890",
+					JSONPatchPatch: []byte(`{"something": something}`),
+					Warnings:       []string{"warn1"},
+					Denied:         true,
+					Message:        "can't be fixed",
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"can't be fixed","code":400},"warnings":["warn1"]}}`,
+			expCode: 200,
+		},
+
+		"A correct mutating admission v1beta1 webhook that denies with custom status should not fail.": {
+			body: getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			mock: func(mw *webhookmock.Webhook) {
+				resp := &model.MutatingAdmissionResponse{
+					ID:      "1234567890",
+					Denied:  true,
+					Message: "can't be fixed",
+					Code:    403,
+					Reason:  metav1.StatusReasonForbidden,
+				}
+				mw.On("Review", mock.Anything, mock.Anything).Once().Return(resp, nil)
+			},
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"can't be fixed","reason":"Forbidden","code":403}}}`,
+			expCode: 200,
+		},
+
 		"A correct mutating admission v1 webhook should not fail.": {
 			body: getTestAdmissionReviewV1RequestStr("1234567890"),
 			mock: func(mw *webhookmock.Webhook) {
@@ -273,3 +407,762 @@ func TestDefaultWebhookFlow(t *testing.T) {
 		})
 	}
 }
+
+func TestFailurePolicy(t *testing.T) {
+	tests := map[string]struct {
+		body          string
+		kind          model.WebhookKind
+		failurePolicy kubewebhookhttp.FailurePolicy
+		failureMsg    string
+		expMetricData webhook.MeasureFailurePolicyOpData
+		expCode       int
+		expBody       string
+	}{
+		"A failed review on v1 with default failure policy should passthrough the error.": {
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			kind: model.WebhookKindValidating,
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "passthrough",
+				Reason:                 "review-error",
+			},
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"wanted error"}}}`,
+		},
+
+		"A failed validating review on v1 with fail open failure policy should allow with a warning.": {
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			kind:          model.WebhookKindValidating,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailOpen,
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-open",
+				Reason:                 "review-error",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"warnings":["the admission webhook could not review the request"]}}`,
+		},
+
+		"A failed mutating review on v1 with fail open failure policy should allow with a warning and without patch.": {
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			kind:          model.WebhookKindMutating,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailOpen,
+			failureMsg:    "custom message",
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "mutating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-open",
+				Reason:                 "review-error",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"patchType":"JSONPatch","warnings":["custom message"]}}`,
+		},
+
+		"A failed validating review on v1beta1 with fail open failure policy should allow.": {
+			body:          getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			kind:          model.WebhookKindValidating,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailOpen,
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1beta1",
+				FailurePolicy:          "fail-open",
+				Reason:                 "review-error",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"A failed review on v1 with fail closed failure policy should deny with the failure message.": {
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			kind:          model.WebhookKindMutating,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailClosed,
+			failureMsg:    "custom message",
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "mutating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-closed",
+				Reason:                 "review-error",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"custom message","reason":"InternalError","code":500}}}`,
+		},
+
+		"A failed review on v1beta1 with fail closed failure policy should deny with the failure message.": {
+			body:          getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			kind:          model.WebhookKindValidating,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailClosed,
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1beta1",
+				FailurePolicy:          "fail-closed",
+				Reason:                 "review-error",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"the admission webhook could not review the request","reason":"InternalError","code":500}}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Mocks.
+			mwh := &webhookmock.Webhook{}
+			mwh.On("Review", mock.Anything, mock.Anything).Once().Return(nil, fmt.Errorf("wanted error"))
+			mwh.On("ID").Maybe().Return("test")
+			mwh.On("Kind").Maybe().Return(test.kind)
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			mrec.On("MeasureFailurePolicyOp", mock.Anything, test.expMetricData).Once()
+
+			h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+				Webhook:         mwh,
+				MetricsRecorder: mrec,
+				FailurePolicy:   test.failurePolicy,
+				FailureMessage:  test.failureMsg,
+			})
+			require.NoError(err)
+
+			req := httptest.NewRequest("GET", "/awesome/webhook", bytes.NewBufferString(test.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := map[string]struct {
+		path          string
+		config        kubewebhookhttp.HandlerConfig
+		reviewLatency time.Duration
+		expDeadline   bool
+		expMetricData *webhook.MeasureFailurePolicyOpData
+		expCode       int
+		expBody       string
+	}{
+		"A review without apiserver timeout should not have deadline.": {
+			path:        "/awesome/webhook",
+			expDeadline: false,
+			expCode:     200,
+			expBody:     `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"A review with an invalid apiserver timeout should not have deadline.": {
+			path:        "/awesome/webhook?timeout=wrong",
+			expDeadline: false,
+			expCode:     200,
+			expBody:     `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"A review with apiserver timeout that ends before the deadline should return the review result.": {
+			path:        "/awesome/webhook?timeout=10s",
+			expDeadline: true,
+			expCode:     200,
+			expBody:     `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"A review with apiserver timeout that reaches the deadline should use the failure policy by default.": {
+			path:          "/awesome/webhook?timeout=1s",
+			config:        kubewebhookhttp.HandlerConfig{TimeoutSafetyMargin: 950 * time.Millisecond, FailurePolicy: kubewebhookhttp.FailurePolicyFailClosed},
+			reviewLatency: 5 * time.Second,
+			expDeadline:   true,
+			expMetricData: &webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-closed",
+				Reason:                 "timeout",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"the admission webhook could not review the request","reason":"InternalError","code":500}}}`,
+		},
+
+		"A review with apiserver timeout that reaches the deadline should use the timeout policy.": {
+			path: "/awesome/webhook?timeout=1s",
+			config: kubewebhookhttp.HandlerConfig{
+				TimeoutSafetyMargin: 950 * time.Millisecond,
+				FailurePolicy:       kubewebhookhttp.FailurePolicyFailClosed,
+				TimeoutPolicy:       kubewebhookhttp.FailurePolicyFailOpen,
+			},
+			reviewLatency: 5 * time.Second,
+			expDeadline:   true,
+			expMetricData: &webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-open",
+				Reason:                 "timeout",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"warnings":["the admission webhook could not review the request"]}}`,
+		},
+
+		"A review with apiserver timeout smaller than the safety margin should use half of the timeout as deadline.": {
+			path:          "/awesome/webhook?timeout=100ms",
+			config:        kubewebhookhttp.HandlerConfig{TimeoutSafetyMargin: 5 * time.Second},
+			reviewLatency: 5 * time.Second,
+			expDeadline:   true,
+			expMetricData: &webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "passthrough",
+				Reason:                 "timeout",
+			},
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"context deadline exceeded"}}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Mocks.
+			mwh := &webhookmock.Webhook{}
+			mwh.On("ID").Maybe().Return("test")
+			mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+			mwh.On("Review", mock.Anything, mock.Anything).Once().Return(func(ctx context.Context, _ model.AdmissionReview) model.AdmissionResponse {
+				_, hasDeadline := ctx.Deadline()
+				assert.Equal(test.expDeadline, hasDeadline)
+				select {
+				case <-time.After(test.reviewLatency):
+				case <-ctx.Done():
+				}
+				return &model.ValidatingAdmissionResponse{ID: "1234567890", Allowed: true}
+			}, nil)
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			if test.expMetricData != nil {
+				mrec.On("MeasureFailurePolicyOp", mock.Anything, *test.expMetricData).Once()
+			}
+
+			test.config.Webhook = mwh
+			test.config.MetricsRecorder = mrec
+			h, err := kubewebhookhttp.HandlerFor(test.config)
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(getTestAdmissionReviewV1RequestStr("1234567890")))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+		})
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := map[string]struct {
+		maxInflight int
+		maxQueue    int
+	}{
+		"Having the max inflight reviews running without queue should shed the new reviews.": {
+			maxInflight: 1,
+			maxQueue:    0,
+		},
+
+		"Having the max inflight reviews running and the queue full should shed the new reviews.": {
+			maxInflight: 2,
+			maxQueue:    2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			started := make(chan struct{}, test.maxInflight+test.maxQueue)
+			queued := make(chan int, 100)
+			unblock := make(chan struct{})
+
+			// Mocks.
+			mwh := &webhookmock.Webhook{}
+			mwh.On("ID").Maybe().Return("test")
+			mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+			mwh.On("Review", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ model.AdmissionReview) model.AdmissionResponse {
+				started <- struct{}{}
+				<-unblock
+				return &model.ValidatingAdmissionResponse{ID: "1234567890", Allowed: true}
+			}, nil)
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			mrec.On("MeasureConcurrencyOp", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				queued <- args.Get(1).(webhook.MeasureConcurrencyOpData).Queued
+			})
+			mrec.On("MeasureFailurePolicyOp", mock.Anything, webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-closed",
+				Reason:                 "load-shed",
+			}).Once()
+
+			h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+				Webhook:         mwh,
+				MetricsRecorder: mrec,
+				FailurePolicy:   kubewebhookhttp.FailurePolicyFailClosed,
+				MaxInflight:     test.maxInflight,
+				MaxQueue:        test.maxQueue,
+			})
+			require.NoError(err)
+
+			serve := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest("POST", "/awesome/webhook", bytes.NewBufferString(getTestAdmissionReviewV1RequestStr("1234567890")))
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				return w
+			}
+
+			// Fill the inflight slots and the queue.
+			respC := make(chan *httptest.ResponseRecorder, test.maxInflight+test.maxQueue)
+			for i := 0; i < test.maxInflight; i++ {
+				go func() { respC <- serve() }()
+				<-started
+			}
+			for i := 0; i < test.maxQueue; i++ {
+				go func() { respC <- serve() }()
+				for q := range queued {
+					if q == i+1 {
+						break
+					}
+				}
+			}
+
+			// This one should be shed.
+			w := serve()
+			assert.Equal(200, w.Code)
+			assert.Equal(`{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"the admission webhook could not review the request","reason":"InternalError","code":500}}}`, w.Body.String())
+
+			// The rest should end correctly.
+			close(unblock)
+			for i := 0; i < test.maxInflight+test.maxQueue; i++ {
+				w := <-respC
+				assert.Equal(200, w.Code)
+				assert.Equal(`{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitConcurrentMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Mocks.
+	mwh := &webhookmock.Webhook{}
+	mwh.On("ID").Maybe().Return("test")
+	mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+	mwh.On("Review", mock.Anything, mock.Anything).Return(&model.ValidatingAdmissionResponse{ID: "1234567890", Allowed: true}, nil)
+	var (
+		mu   sync.Mutex
+		last webhook.MeasureConcurrencyOpData
+	)
+	mrec := webhookmock.NewFullMetricsRecorder(t)
+	mrec.On("MeasureConcurrencyOp", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		// Slow recorder, to interleave the concurrent measurements.
+		time.Sleep(time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		last = args.Get(1).(webhook.MeasureConcurrencyOpData)
+	})
+
+	h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+		Webhook:         mwh,
+		MetricsRecorder: mrec,
+		MaxInflight:     2,
+		MaxQueue:        200,
+	})
+	require.NoError(err)
+
+	// Execute the reviews concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/awesome/webhook", bytes.NewBufferString(getTestAdmissionReviewV1RequestStr("1234567890")))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	// Once all the reviews have ended, the last measurement should be idle.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(0, last.Inflight)
+	assert.Equal(0, last.Queued)
+}
+
+func TestConcurrencyLimitReviewOutlivesDeadline(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	started := make(chan struct{}, 1)
+	inflight := make(chan int, 100)
+	unblock := make(chan struct{})
+
+	// Mocks.
+	mwh := &webhookmock.Webhook{}
+	mwh.On("ID").Maybe().Return("test")
+	mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+	mwh.On("Review", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ model.AdmissionReview) model.AdmissionResponse {
+		// Ignore the context deadline.
+		started <- struct{}{}
+		<-unblock
+		return &model.ValidatingAdmissionResponse{ID: "1234567890", Allowed: true}
+	}, nil)
+	mrec := webhookmock.NewFullMetricsRecorder(t)
+	mrec.On("MeasureConcurrencyOp", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		inflight <- args.Get(1).(webhook.MeasureConcurrencyOpData).Inflight
+	})
+	for _, reason := range []string{"timeout", "load-shed"} {
+		mrec.On("MeasureFailurePolicyOp", mock.Anything, webhook.MeasureFailurePolicyOpData{
+			WebhookID:              "test",
+			WebhookType:            "validating",
+			AdmissionReviewVersion: "v1",
+			FailurePolicy:          "fail-closed",
+			Reason:                 reason,
+		}).Once()
+	}
+
+	h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+		Webhook:             mwh,
+		MetricsRecorder:     mrec,
+		FailurePolicy:       kubewebhookhttp.FailurePolicyFailClosed,
+		TimeoutSafetyMargin: 50 * time.Millisecond,
+		MaxInflight:         1,
+	})
+	require.NoError(err)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(getTestAdmissionReviewV1RequestStr("1234567890")))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	expDenied := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"the admission webhook could not review the request","reason":"InternalError","code":500}}}`
+
+	// The review reaches the deadline, but the webhook review is still running.
+	w := serve("/awesome/webhook?timeout=100ms")
+	<-started
+	assert.Equal(200, w.Code)
+	assert.Equal(expDenied, w.Body.String())
+	assert.Equal(1, <-inflight)
+	assert.Len(inflight, 0)
+
+	// The slot is still in use, so the review should be shed.
+	w = serve("/awesome/webhook")
+	assert.Equal(200, w.Code)
+	assert.Equal(expDenied, w.Body.String())
+
+	// Once the webhook review ends the slot should be released.
+	close(unblock)
+	select {
+	case got := <-inflight:
+		assert.Equal(0, got)
+	case <-time.After(5 * time.Second):
+		require.FailNow("the execution slot was not released")
+	}
+
+	w = serve("/awesome/webhook")
+	<-started
+	assert.Equal(200, w.Code)
+	assert.Equal(`{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`, w.Body.String())
+}
+
+// endTraceRecorder is a tracer that records the errors of the ended traces.
+type endTraceRecorder struct {
+	tracing.Tracer
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *endTraceRecorder) WithValues(map[string]interface{}) tracing.Tracer { return e }
+
+func (e *endTraceRecorder) EndTrace(_ context.Context, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, err)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	tests := map[string]struct {
+		path          string
+		body          string
+		failurePolicy kubewebhookhttp.FailurePolicy
+		expMetricData webhook.MeasureFailurePolicyOpData
+		expCode       int
+		expBody       string
+	}{
+		"A panic on a v1 review should return an error admission review response.": {
+			path: "/awesome/webhook",
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "passthrough",
+				Reason:                 "panic",
+			},
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"admission review panic: wanted panic"}}}`,
+		},
+
+		"A panic on a v1beta1 review should return an error admission review response.": {
+			path: "/awesome/webhook",
+			body: getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1beta1",
+				FailurePolicy:          "passthrough",
+				Reason:                 "panic",
+			},
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"admission review panic: wanted panic"}}}`,
+		},
+
+		"A panic on a review with deadline should return an error admission review response.": {
+			path: "/awesome/webhook?timeout=10s",
+			body: getTestAdmissionReviewV1RequestStr("1234567890"),
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "passthrough",
+				Reason:                 "panic",
+			},
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"admission review panic: wanted panic"}}}`,
+		},
+
+		"A panic on a review should use the failure policy.": {
+			path:          "/awesome/webhook",
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			failurePolicy: kubewebhookhttp.FailurePolicyFailOpen,
+			expMetricData: webhook.MeasureFailurePolicyOpData{
+				WebhookID:              "test",
+				WebhookType:            "validating",
+				AdmissionReviewVersion: "v1",
+				FailurePolicy:          "fail-open",
+				Reason:                 "panic",
+			},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"warnings":["the admission webhook could not review the request"]}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Mocks.
+			mwh := &webhookmock.Webhook{}
+			mwh.On("ID").Maybe().Return("test")
+			mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+			mwh.On("Review", mock.Anything, mock.Anything).Once().Run(func(mock.Arguments) { panic("wanted panic") })
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			mrec.On("MeasureFailurePolicyOp", mock.Anything, test.expMetricData).Once()
+
+			tracer := &endTraceRecorder{Tracer: tracing.Noop}
+
+			h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+				Webhook:         mwh,
+				MetricsRecorder: mrec,
+				Tracer:          tracer,
+				FailurePolicy:   test.failurePolicy,
+			})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+
+			// The panic should be traced as an error.
+			if assert.Len(tracer.errs, 1) {
+				assert.EqualError(tracer.errs[0], "admission review panic: wanted panic")
+			}
+		})
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	admissionErr := &webhook.AdmissionError{
+		Message: "invalid pod",
+		Code:    422,
+		Reason:  metav1.StatusReasonInvalid,
+		Causes: []metav1.StatusCause{
+			{Type: metav1.CauseTypeFieldValueInvalid, Message: "must be positive", Field: "spec.replicas"},
+		},
+	}
+
+	tests := map[string]struct {
+		body          string
+		err           error
+		failurePolicy kubewebhookhttp.FailurePolicy
+		expCode       int
+		expBody       string
+	}{
+		"A v1 admission error should be mapped to the status.": {
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:     admissionErr,
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"invalid pod","reason":"Invalid","details":{"causes":[{"reason":"FieldValueInvalid","message":"must be positive","field":"spec.replicas"}]},"code":422}}}`,
+		},
+
+		"A v1beta1 admission error should be mapped to the status.": {
+			body:    getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			err:     admissionErr,
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"invalid pod","reason":"Invalid","details":{"causes":[{"reason":"FieldValueInvalid","message":"must be positive","field":"spec.replicas"}]},"code":422}}}`,
+		},
+
+		"A wrapped admission error should be mapped to the status.": {
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:     fmt.Errorf("validator error: %w", &webhook.AdmissionError{Code: 403, Reason: metav1.StatusReasonForbidden, Err: fmt.Errorf("not allowed user")}),
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"not allowed user","reason":"Forbidden","code":403}}}`,
+		},
+
+		"A Kubernetes API error should not be mapped to the status.": {
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:     apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("not allowed user")),
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"pods \"test\" is forbidden: not allowed user"}}}`,
+		},
+
+		"A Kubernetes API error with fail closed failure policy should deny with the failure message.": {
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:           apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("not allowed user")),
+			failurePolicy: kubewebhookhttp.FailurePolicyFailClosed,
+			expCode:       200,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"the admission webhook could not review the request","reason":"InternalError","code":500}}}`,
+		},
+
+		"A Kubernetes API error wrapped on an admission error should be mapped to the admission error status.": {
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:     &webhook.AdmissionError{Code: 403, Reason: metav1.StatusReasonForbidden, Err: apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "test", fmt.Errorf("not allowed user"))},
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"pods \"test\" is forbidden: not allowed user","reason":"Forbidden","code":403}}}`,
+		},
+
+		"An admission error with fail closed failure policy should deny with the admission error status.": {
+			body:          getTestAdmissionReviewV1RequestStr("1234567890"),
+			err:           admissionErr,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailClosed,
+			expCode:       200,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"invalid pod","reason":"Invalid","details":{"causes":[{"reason":"FieldValueInvalid","message":"must be positive","field":"spec.replicas"}]},"code":422}}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Mocks.
+			mwh := &webhookmock.Webhook{}
+			mwh.On("ID").Maybe().Return("test")
+			mwh.On("Kind").Maybe().Return(model.WebhookKind(model.WebhookKindValidating))
+			mwh.On("Review", mock.Anything, mock.Anything).Once().Return(nil, test.err)
+
+			h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+				Webhook:       mwh,
+				FailurePolicy: test.failurePolicy,
+			})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", "/awesome/webhook", bytes.NewBufferString(test.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+		})
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	slowValidator := validating.ValidatorFunc(func(ctx context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+		<-ctx.Done()
+		return &validating.ValidatorResult{Valid: true}, nil
+	})
+
+	tests := map[string]struct {
+		timeoutPolicy webhook.StepTimeoutPolicy
+		failurePolicy kubewebhookhttp.FailurePolicy
+		expCode       int
+		expBody       string
+	}{
+		"A step timeout with skip policy should allow the request.": {
+			timeoutPolicy: webhook.StepTimeoutPolicySkip,
+			expCode:       200,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"A step timeout with deny policy should deny the request.": {
+			timeoutPolicy: webhook.StepTimeoutPolicyDeny,
+			expCode:       200,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"\"test\" step timed out after 10ms","reason":"Timeout","code":504}}}`,
+		},
+
+		"A step timeout with error policy should fail the review with the passthrough failure policy.": {
+			timeoutPolicy: webhook.StepTimeoutPolicyError,
+			expCode:       500,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"validator error: \"test\" step timed out after 10ms"}}}`,
+		},
+
+		"A step timeout with error policy should use the failure policy.": {
+			timeoutPolicy: webhook.StepTimeoutPolicyError,
+			failurePolicy: kubewebhookhttp.FailurePolicyFailOpen,
+			expCode:       200,
+			expBody:       `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"warnings":["the admission webhook could not review the request"]}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			v, err := validating.NewTimeoutValidator(validating.TimeoutValidatorConfig{
+				Name:          "test",
+				Validator:     slowValidator,
+				Timeout:       10 * time.Millisecond,
+				TimeoutPolicy: test.timeoutPolicy,
+			})
+			require.NoError(err)
+			wh, err := validating.NewWebhook(validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, Validator: v})
+			require.NoError(err)
+
+			h, err := kubewebhookhttp.HandlerFor(kubewebhookhttp.HandlerConfig{
+				Webhook:       wh,
+				FailurePolicy: test.failurePolicy,
+			})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", "/awesome/webhook", bytes.NewBufferString(getTestAdmissionReviewV1RequestStr("1234567890")))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"sync"
+
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// limiter limits the number of concurrent admission reviews, the reviews that can't be
+// executed at the moment will wait on a bounded queue.
+type limiter struct {
+	slots    chan struct{}
+	maxQueue int
+
+	mu       sync.Mutex
+	queued   int
+	inflight int
+
+	webhookID   string
+	webhookType string
+	metricsRec  webhook.ConcurrencyMetricsRecorder
+}
+
+func newLimiter(maxInflight, maxQueue int, wh webhook.Webhook, rec webhook.ConcurrencyMetricsRecorder) *limiter {
+	return &limiter{
+		slots:       make(chan struct{}, maxInflight),
+		maxQueue:    maxQueue,
+		webhookID:   wh.ID(),
+		webhookType: string(wh.Kind()),
+		metricsRec:  rec,
+	}
+}
+
+// acquire will try getting an execution slot, if there are no free slots, it will wait
+// on the queue until a slot is released or the context is done. If the queue is full
+// or the context is done, it will return false, this means that the review should be shed.
+// When the returned value is true, the caller must call release after the execution.
+func (l *limiter) acquire(ctx context.Context) bool {
+	// Fast path, we have a free slot.
+	select {
+	case l.slots <- struct{}{}:
+		l.update(ctx, 1, 0)
+		return true
+	default:
+	}
+
+	// Queue the review if we have space.
+	if !l.enqueue(ctx) {
+		return false
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		l.update(ctx, 1, -1)
+		return true
+	case <-ctx.Done():
+		l.update(ctx, 0, -1)
+		return false
+	}
+}
+
+// release releases an execution slot acquired previously.
+func (l *limiter) release(ctx context.Context) {
+	<-l.slots
+	l.update(ctx, -1, 0)
+}
+
+// enqueue adds the review to the queue, if the queue is full it will return false.
+func (l *limiter) enqueue(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.queued >= l.maxQueue {
+		return false
+	}
+	l.queued++
+	l.measure(ctx)
+
+	return true
+}
+
+func (l *limiter) update(ctx context.Context, inflight, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight += inflight
+	l.queued += queued
+	l.measure(ctx)
+}
+
+// measure measures the current inflight and queued reviews, it must be called with the lock held,
+// this way the measurements are made in the same order as the changes.
+func (l *limiter) measure(ctx context.Context) {
+	l.metricsRec.MeasureConcurrencyOp(ctx, webhook.MeasureConcurrencyOpData{
+		WebhookID:   l.webhookID,
+		WebhookType: l.webhookType,
+		Inflight:    l.inflight,
+		Queued:      l.queued,
+	})
+}
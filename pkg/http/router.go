@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// DefaultRouterPathTemplate is the path template used by the router when the user doesn't set
+// one (e.g: `/webhooks/mutating/pod-annotate`).
+const DefaultRouterPathTemplate = "/webhooks/{{ .Kind }}/{{ .ID }}"
+
+// RouterPathData is the data that will be available on the router path template
+// to create the path of each webhook.
+type RouterPathData struct {
+	ID   string
+	Kind model.WebhookKind
+}
+
+// RouterConfig is the configuration of the multi webhook router.
+type RouterConfig struct {
+	// Webhooks are the webhooks that will be served by the router, the webhook IDs must be unique.
+	Webhooks []webhook.Webhook
+	// PathTemplate is a Go text template that will be used to create the path of each webhook,
+	// it receives `RouterPathData` as data. By default `DefaultRouterPathTemplate`.
+	PathTemplate string
+	// Logger is the logger shared by the router and all the webhook handlers.
+	Logger log.Logger
+	// Tracer is the tracer shared by the router and all the webhook handlers.
+	Tracer tracing.Tracer
+	// MetricsRecorder is the metrics recorder shared by all the webhook handlers.
+	MetricsRecorder webhook.MetricsRecorder
+	// HandlerConfigs are the handler configurations of the webhooks by webhook ID, to customize the handler of
+	// each webhook (e.g: failure policy, timeout policy, concurrency limits...). The webhook is set by the router,
+	// and if the logger, tracer or metrics recorder are not set, the shared ones will be used.
+	HandlerConfigs map[string]HandlerConfig
+}
+
+func (c *RouterConfig) defaults() error {
+	if len(c.Webhooks) == 0 {
+		return fmt.Errorf("at least one webhook is required")
+	}
+
+	if c.PathTemplate == "" {
+		c.PathTemplate = DefaultRouterPathTemplate
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	ids := map[string]bool{}
+	for _, wh := range c.Webhooks {
+		if wh != nil {
+			ids[wh.ID()] = true
+		}
+	}
+	for id := range c.HandlerConfigs {
+		if !ids[id] {
+			return fmt.Errorf("handler configuration for unknown %q webhook", id)
+		}
+	}
+
+	return nil
+}
+
+// MustRouterFor it's the same as RouterFor but will panic instead of returning
+// a error.
+func MustRouterFor(config RouterConfig) http.Handler {
+	h, err := RouterFor(config)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// RouterFor returns a new http.Handler that will serve multiple webhooks, each one of them
+// on its own path based on the webhook ID and kind.
+//
+// Requests to unknown paths will be answered with a not found admission review response.
+func RouterFor(config RouterConfig) (http.Handler, error) {
+	err := config.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("router invalid configuration: %w", err)
+	}
+
+	tpl, err := template.New("path").Parse(config.PathTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+
+	logger := config.Logger.WithValues(log.Kv{"svc": "http.Router"})
+	r := router{
+		routes: map[string]http.Handler{},
+		logger: logger,
+	}
+	ids := map[string]bool{}
+	for _, wh := range config.Webhooks {
+		if wh == nil {
+			return nil, fmt.Errorf("webhook can't be nil")
+		}
+
+		id := wh.ID()
+		if ids[id] {
+			return nil, fmt.Errorf("duplicated webhook ID %q", id)
+		}
+		ids[id] = true
+
+		var b bytes.Buffer
+		err := tpl.Execute(&b, RouterPathData{ID: id, Kind: wh.Kind()})
+		if err != nil {
+			return nil, fmt.Errorf("could not render %q webhook path: %w", id, err)
+		}
+		path := b.String()
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid %q webhook path %q: must start with '/'", id, path)
+		}
+		if _, ok := r.routes[path]; ok {
+			return nil, fmt.Errorf("duplicated webhook path %q on %q webhook", path, id)
+		}
+
+		hcfg := config.HandlerConfigs[id]
+		hcfg.Webhook = wh
+		if hcfg.Logger == nil {
+			hcfg.Logger = config.Logger
+		}
+		if hcfg.Tracer == nil {
+			hcfg.Tracer = config.Tracer
+		}
+		if hcfg.MetricsRecorder == nil {
+			hcfg.MetricsRecorder = config.MetricsRecorder
+		}
+		h, err := HandlerFor(hcfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not create %q webhook handler: %w", id, err)
+		}
+		r.routes[path] = h
+
+		logger.WithValues(log.Kv{"webhook-id": id, "path": path}).Debugf("Webhook handler registered on router")
+	}
+
+	return r, nil
+}
+
+type router struct {
+	routes map[string]http.Handler
+	logger log.Logger
+}
+
+func (r router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h, ok := r.routes[req.URL.Path]; ok {
+		h.ServeHTTP(w, req)
+		return
+	}
+
+	r.logger.WithValues(log.Kv{"path": req.URL.Path}).Warningf("No webhook registered on path")
+
+	// Try getting the admission review so we can answer with the correct version and UID, if
+	// we can't, fallback to a v1 admission review.
+	review := model.AdmissionReview{OriginalAdmissionReview: &admissionv1.AdmissionReview{}}
+	if req.Body != nil {
+		if body, err := configReader(req); err == nil && len(body) > 0 {
+			if ar, err := requestBodyToModelReview(body); err == nil {
+				review = *ar
+			}
+		}
+	}
+
+	resp, err := statusToJSON(review, metav1.Status{
+		Message: fmt.Sprintf("no webhook registered on %q path", req.URL.Path),
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonNotFound,
+		Code:    http.StatusNotFound,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("could not marshall status error on admission response: %v", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		r.logger.Errorf(msg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	if _, err := w.Write(resp); err != nil {
+		r.logger.Errorf("could not write response: %v", err)
+	}
+}
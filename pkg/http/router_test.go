@@ -0,0 +1,252 @@
+package http_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	kubewebhookhttp "github.com/slok/kubewebhook/v2/pkg/http"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func newRouterWebhookMock(id string, kind model.WebhookKind, allowed bool) *webhookmock.Webhook {
+	mwh := &webhookmock.Webhook{}
+	mwh.On("ID").Maybe().Return(id)
+	mwh.On("Kind").Maybe().Return(kind)
+	mwh.On("Review", mock.Anything, mock.Anything).Maybe().Return(&model.ValidatingAdmissionResponse{ID: "1234567890", Allowed: allowed}, nil)
+	return mwh
+}
+
+func newRouterFailingWebhookMock(id string, kind model.WebhookKind) *webhookmock.Webhook {
+	mwh := &webhookmock.Webhook{}
+	mwh.On("ID").Maybe().Return(id)
+	mwh.On("Kind").Maybe().Return(kind)
+	mwh.On("Review", mock.Anything, mock.Anything).Maybe().Return(nil, fmt.Errorf("something"))
+	return mwh
+}
+
+func TestRouter(t *testing.T) {
+	tests := map[string]struct {
+		config  func() kubewebhookhttp.RouterConfig
+		path    string
+		body    string
+		expErr  bool
+		expCode int
+		expBody string
+	}{
+		"Not having webhooks should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{}
+			},
+			expErr: true,
+		},
+
+		"Having duplicated webhook IDs should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+						newRouterWebhookMock("wh1", model.WebhookKindMutating, true),
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Having a template that renders duplicated paths should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					PathTemplate: "/{{ .Kind }}",
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+						newRouterWebhookMock("wh2", model.WebhookKindValidating, true),
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Having a template that renders invalid paths should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					PathTemplate: "{{ .ID }}",
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Having an invalid template should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					PathTemplate: "/{{ .ID ",
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Requests to the default path of a webhook should be handled by the webhook.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+						newRouterWebhookMock("wh2", model.WebhookKindValidating, false),
+					},
+				}
+			},
+			path:    "/webhooks/validating/wh1",
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true}}`,
+		},
+
+		"Requests to a custom template path of a webhook should be handled by the webhook.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					PathTemplate: "/wh/{{ .ID }}",
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+						newRouterWebhookMock("wh2", model.WebhookKindValidating, false),
+					},
+				}
+			},
+			path:    "/wh/wh2",
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","code":400}}}`,
+		},
+
+		"Having handler configurations of unknown webhooks should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+					},
+					HandlerConfigs: map[string]kubewebhookhttp.HandlerConfig{
+						"wh2": {FailurePolicy: kubewebhookhttp.FailurePolicyFailOpen},
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Having invalid webhook handler configurations should fail.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterWebhookMock("wh1", model.WebhookKindValidating, true),
+					},
+					HandlerConfigs: map[string]kubewebhookhttp.HandlerConfig{
+						"wh1": {FailurePolicy: "something"},
+					},
+				}
+			},
+			expErr: true,
+		},
+
+		"Requests to a webhook with a handler configuration should use the webhook handler configuration.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterFailingWebhookMock("wh1", model.WebhookKindValidating),
+						newRouterFailingWebhookMock("wh2", model.WebhookKindValidating),
+					},
+					HandlerConfigs: map[string]kubewebhookhttp.HandlerConfig{
+						"wh2": {FailurePolicy: kubewebhookhttp.FailurePolicyFailOpen},
+					},
+				}
+			},
+			path:    "/webhooks/validating/wh2",
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			expCode: 200,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":true,"warnings":["the admission webhook could not review the request"]}}`,
+		},
+
+		"Requests to a webhook without a handler configuration should use the default handler configuration.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{
+						newRouterFailingWebhookMock("wh1", model.WebhookKindValidating),
+						newRouterFailingWebhookMock("wh2", model.WebhookKindValidating),
+					},
+					HandlerConfigs: map[string]kubewebhookhttp.HandlerConfig{
+						"wh2": {FailurePolicy: kubewebhookhttp.FailurePolicyFailOpen},
+					},
+				}
+			},
+			path:    "/webhooks/validating/wh1",
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			expCode: 500,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"something"}}}`,
+		},
+
+		"Requests to unknown paths with a v1beta1 admission review should return a not found v1beta1 admission review.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{newRouterWebhookMock("wh1", model.WebhookKindValidating, true)},
+				}
+			},
+			path:    "/webhooks/validating/wh2",
+			body:    getTestAdmissionReviewV1beta1RequestStr("1234567890"),
+			expCode: 404,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1beta1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"no webhook registered on \"/webhooks/validating/wh2\" path","reason":"NotFound","code":404}}}`,
+		},
+
+		"Requests to unknown paths with a v1 admission review should return a not found v1 admission review.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{newRouterWebhookMock("wh1", model.WebhookKindValidating, true)},
+				}
+			},
+			path:    "/webhooks/validating/wh2",
+			body:    getTestAdmissionReviewV1RequestStr("1234567890"),
+			expCode: 404,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"1234567890","allowed":false,"status":{"metadata":{},"status":"Failure","message":"no webhook registered on \"/webhooks/validating/wh2\" path","reason":"NotFound","code":404}}}`,
+		},
+
+		"Requests to unknown paths without a valid admission review should return a not found v1 admission review.": {
+			config: func() kubewebhookhttp.RouterConfig {
+				return kubewebhookhttp.RouterConfig{
+					Webhooks: []webhook.Webhook{newRouterWebhookMock("wh1", model.WebhookKindValidating, true)},
+				}
+			},
+			path:    "/something",
+			body:    "wrong body",
+			expCode: 404,
+			expBody: `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","response":{"uid":"","allowed":false,"status":{"metadata":{},"status":"Failure","message":"no webhook registered on \"/something\" path","reason":"NotFound","code":404}}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			h, err := kubewebhookhttp.RouterFor(test.config())
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(test.expCode, w.Code)
+			assert.Equal(test.expBody, w.Body.String())
+		})
+	}
+}
@@ -37,6 +37,15 @@ type Recorder struct {
 	webhookValReviewDuration *prometheus.HistogramVec
 	webhookMutReviewDuration *prometheus.HistogramVec
 	webhookReviewWarnings    *prometheus.CounterVec
+	webhookFailurePolicy     *prometheus.CounterVec
+	webhookInflightReviews   *prometheus.GaugeVec
+	webhookQueuedReviews     *prometheus.GaugeVec
+	webhookMutIdempotency    *prometheus.CounterVec
+	validatorEnforcement     *prometheus.CounterVec
+	webhookCanaryReviews     *prometheus.CounterVec
+	webhookStepTimeouts      *prometheus.CounterVec
+	dependencyCBState        *prometheus.GaugeVec
+	dependencyCBChanges      *prometheus.CounterVec
 }
 
 // NewRecorder returns a new Prometheus metrics recorder.
@@ -69,6 +78,69 @@ func NewRecorder(config RecorderConfig) (*Recorder, error) {
 			Name:      "review_warnings_total",
 			Help:      "The total number warnings the webhooks are returning on the review process.",
 		}, []string{"webhook_id", "webhook_version", "resource_namespace", "resource_kind", "operation", "dry_run", "success"}),
+
+		webhookFailurePolicy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "webhook",
+			Name:      "failure_policy_applied_total",
+			Help:      "The total number of failure policies applied on failed admission reviews.",
+		}, []string{"webhook_id", "webhook_type", "webhook_version", "failure_policy", "reason"}),
+
+		webhookInflightReviews: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Subsystem: "webhook",
+			Name:      "inflight_reviews",
+			Help:      "The number of admission reviews being handled at this moment by a concurrency limited webhook handler.",
+		}, []string{"webhook_id", "webhook_type"}),
+
+		webhookQueuedReviews: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Subsystem: "webhook",
+			Name:      "queued_reviews",
+			Help:      "The number of admission reviews waiting to be handled by a concurrency limited webhook handler.",
+		}, []string{"webhook_id", "webhook_type"}),
+
+		webhookMutIdempotency: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "mutating_webhook",
+			Name:      "idempotency_checks_total",
+			Help:      "The total number of mutation idempotency checks made by the mutating webhooks.",
+		}, []string{"webhook_id", "webhook_version", "resource_kind", "operation", "idempotent"}),
+
+		validatorEnforcement: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "validator",
+			Name:      "enforcement_decisions_total",
+			Help:      "The total number of decisions made by the enforcement validators.",
+		}, []string{"validator", "enforcement_mode", "decision"}),
+
+		webhookCanaryReviews: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "webhook",
+			Name:      "canary_reviews_total",
+			Help:      "The total number of reviews handled by the stable and canary variants of a canary rollout.",
+		}, []string{"canary", "webhook_type", "variant", "success", "allowed"}),
+
+		webhookStepTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "webhook",
+			Name:      "step_timeouts_total",
+			Help:      "The total number of timed out webhook steps (mutators and validators).",
+		}, []string{"step", "webhook_type", "timeout_policy"}),
+
+		dependencyCBState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prefix,
+			Subsystem: "dependency",
+			Name:      "circuit_breaker_state",
+			Help:      "The current state of the dependencies circuit breakers (1 on the current state, 0 on the rest).",
+		}, []string{"dependency", "state"}),
+
+		dependencyCBChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "dependency",
+			Name:      "circuit_breaker_state_changes_total",
+			Help:      "The total number of state changes of the dependencies circuit breakers, by the new state.",
+		}, []string{"dependency", "state"}),
 	}
 
 	// Register our metrics on the received recorder.
@@ -76,12 +148,21 @@ func NewRecorder(config RecorderConfig) (*Recorder, error) {
 		r.webhookValReviewDuration,
 		r.webhookMutReviewDuration,
 		r.webhookReviewWarnings,
+		r.webhookFailurePolicy,
+		r.webhookInflightReviews,
+		r.webhookQueuedReviews,
+		r.webhookMutIdempotency,
+		r.validatorEnforcement,
+		r.webhookCanaryReviews,
+		r.webhookStepTimeouts,
+		r.dependencyCBState,
+		r.dependencyCBChanges,
 	)
 
 	return r, nil
 }
 
-var _ webhook.MetricsRecorder = Recorder{}
+var _ webhook.FullMetricsRecorder = Recorder{}
 
 // MeasureValidatingWebhookReviewOp measures a validating webhook review operation on Prometheus.
 func (r Recorder) MeasureValidatingWebhookReviewOp(_ context.Context, data webhook.MeasureValidatingOpData) {
@@ -134,3 +215,80 @@ func (r Recorder) MeasureMutatingWebhookReviewOp(_ context.Context, data webhook
 		"success":            strconv.FormatBool(data.Success),
 	}).Add(float64(data.WarningsNumber))
 }
+
+// MeasureFailurePolicyOp measures a failure policy applied on a failed admission review on Prometheus.
+func (r Recorder) MeasureFailurePolicyOp(_ context.Context, data webhook.MeasureFailurePolicyOpData) {
+	r.webhookFailurePolicy.With(prometheus.Labels{
+		"webhook_id":      data.WebhookID,
+		"webhook_type":    data.WebhookType,
+		"webhook_version": data.AdmissionReviewVersion,
+		"failure_policy":  data.FailurePolicy,
+		"reason":          data.Reason,
+	}).Inc()
+}
+
+// MeasureConcurrencyOp measures the concurrency state of a webhook handler on Prometheus.
+func (r Recorder) MeasureConcurrencyOp(_ context.Context, data webhook.MeasureConcurrencyOpData) {
+	labels := prometheus.Labels{
+		"webhook_id":   data.WebhookID,
+		"webhook_type": data.WebhookType,
+	}
+	r.webhookInflightReviews.With(labels).Set(float64(data.Inflight))
+	r.webhookQueuedReviews.With(labels).Set(float64(data.Queued))
+}
+
+// MeasureMutationIdempotencyOp measures a mutation idempotency check of a mutating webhook on Prometheus.
+func (r Recorder) MeasureMutationIdempotencyOp(_ context.Context, data webhook.MeasureMutationIdempotencyOpData) {
+	r.webhookMutIdempotency.With(prometheus.Labels{
+		"webhook_id":      data.WebhookID,
+		"webhook_version": data.AdmissionReviewVersion,
+		"resource_kind":   data.ResourceKind,
+		"operation":       data.Operation,
+		"idempotent":      strconv.FormatBool(data.Idempotent),
+	}).Inc()
+}
+
+// MeasureValidatorEnforcementOp measures the decision of an enforcement validator on Prometheus.
+func (r Recorder) MeasureValidatorEnforcementOp(_ context.Context, data webhook.MeasureValidatorEnforcementOpData) {
+	r.validatorEnforcement.With(prometheus.Labels{
+		"validator":        data.ValidatorName,
+		"enforcement_mode": data.EnforcementMode,
+		"decision":         data.Decision,
+	}).Inc()
+}
+
+// MeasureCanaryOp measures a review of a canary rollout on Prometheus.
+func (r Recorder) MeasureCanaryOp(_ context.Context, data webhook.MeasureCanaryOpData) {
+	r.webhookCanaryReviews.With(prometheus.Labels{
+		"canary":       data.CanaryName,
+		"webhook_type": data.WebhookType,
+		"variant":      data.Variant,
+		"success":      strconv.FormatBool(data.Success),
+		"allowed":      strconv.FormatBool(data.Allowed),
+	}).Inc()
+}
+
+// MeasureStepTimeoutOp measures a timed out webhook step on Prometheus.
+func (r Recorder) MeasureStepTimeoutOp(_ context.Context, data webhook.MeasureStepTimeoutOpData) {
+	r.webhookStepTimeouts.With(prometheus.Labels{
+		"step":           data.StepName,
+		"webhook_type":   data.WebhookType,
+		"timeout_policy": data.TimeoutPolicy,
+	}).Inc()
+}
+
+// MeasureDependencyCircuitBreakerOp measures a state change of a dependency circuit breaker on Prometheus.
+func (r Recorder) MeasureDependencyCircuitBreakerOp(_ context.Context, data webhook.MeasureDependencyCircuitBreakerOpData) {
+	for _, state := range []webhook.CircuitBreakerState{webhook.CircuitBreakerStateClosed, webhook.CircuitBreakerStateOpen, webhook.CircuitBreakerStateHalfOpen} {
+		value := 0.0
+		if string(state) == data.State {
+			value = 1
+		}
+		r.dependencyCBState.With(prometheus.Labels{"dependency": data.Dependency, "state": string(state)}).Set(value)
+	}
+
+	r.dependencyCBChanges.With(prometheus.Labels{
+		"dependency": data.Dependency,
+		"state":      data.State,
+	}).Inc()
+}
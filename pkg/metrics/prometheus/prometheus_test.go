@@ -135,6 +135,141 @@ func TestRecorder(t *testing.T) {
 				`kubewebhook_webhook_review_warnings_total{dry_run="true",operation="delete",resource_kind="core/v1/Pod",resource_namespace="test-ns",success="false",webhook_id="test-wh",webhook_version="v1"} 5`,
 			},
 		},
+
+		"Measure failure policy applied.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureFailurePolicyOpData{
+					WebhookID:              "test-wh",
+					WebhookType:            "validating",
+					AdmissionReviewVersion: "v1",
+					FailurePolicy:          "fail-open",
+					Reason:                 "review-error",
+				}
+				r.MeasureFailurePolicyOp(context.TODO(), d)
+				r.MeasureFailurePolicyOp(context.TODO(), d)
+				d.FailurePolicy = "fail-closed"
+				r.MeasureFailurePolicyOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_webhook_failure_policy_applied_total The total number of failure policies applied on failed admission reviews.`,
+				`# TYPE kubewebhook_webhook_failure_policy_applied_total counter`,
+				`kubewebhook_webhook_failure_policy_applied_total{failure_policy="fail-closed",reason="review-error",webhook_id="test-wh",webhook_type="validating",webhook_version="v1"} 1`,
+				`kubewebhook_webhook_failure_policy_applied_total{failure_policy="fail-open",reason="review-error",webhook_id="test-wh",webhook_type="validating",webhook_version="v1"} 2`,
+			},
+		},
+
+		"Measure concurrency.": {
+			measure: func(r *metrics.Recorder) {
+				r.MeasureConcurrencyOp(context.TODO(), webhook.MeasureConcurrencyOpData{WebhookID: "test-wh", WebhookType: "validating", Inflight: 5, Queued: 3})
+				r.MeasureConcurrencyOp(context.TODO(), webhook.MeasureConcurrencyOpData{WebhookID: "test-wh", WebhookType: "validating", Inflight: 4, Queued: 2})
+				r.MeasureConcurrencyOp(context.TODO(), webhook.MeasureConcurrencyOpData{WebhookID: "test2-wh", WebhookType: "mutating", Inflight: 1, Queued: 0})
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_webhook_inflight_reviews The number of admission reviews being handled at this moment by a concurrency limited webhook handler.`,
+				`# TYPE kubewebhook_webhook_inflight_reviews gauge`,
+				`kubewebhook_webhook_inflight_reviews{webhook_id="test-wh",webhook_type="validating"} 4`,
+				`kubewebhook_webhook_inflight_reviews{webhook_id="test2-wh",webhook_type="mutating"} 1`,
+
+				`# HELP kubewebhook_webhook_queued_reviews The number of admission reviews waiting to be handled by a concurrency limited webhook handler.`,
+				`# TYPE kubewebhook_webhook_queued_reviews gauge`,
+				`kubewebhook_webhook_queued_reviews{webhook_id="test-wh",webhook_type="validating"} 2`,
+				`kubewebhook_webhook_queued_reviews{webhook_id="test2-wh",webhook_type="mutating"} 0`,
+			},
+		},
+
+		"Measure mutation idempotency.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureMutationIdempotencyOpData{
+					WebhookID:              "test-wh",
+					AdmissionReviewVersion: "v1",
+					ResourceKind:           "core/v1/Pod",
+					Operation:              "create",
+					Idempotent:             true,
+				}
+				r.MeasureMutationIdempotencyOp(context.TODO(), d)
+				r.MeasureMutationIdempotencyOp(context.TODO(), d)
+				d.Idempotent = false
+				r.MeasureMutationIdempotencyOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_mutating_webhook_idempotency_checks_total The total number of mutation idempotency checks made by the mutating webhooks.`,
+				`# TYPE kubewebhook_mutating_webhook_idempotency_checks_total counter`,
+				`kubewebhook_mutating_webhook_idempotency_checks_total{idempotent="false",operation="create",resource_kind="core/v1/Pod",webhook_id="test-wh",webhook_version="v1"} 1`,
+				`kubewebhook_mutating_webhook_idempotency_checks_total{idempotent="true",operation="create",resource_kind="core/v1/Pod",webhook_id="test-wh",webhook_version="v1"} 2`,
+			},
+		},
+
+		"Measure validator enforcement.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureValidatorEnforcementOpData{ValidatorName: "test-policy", EnforcementMode: "warn", Decision: "warned"}
+				r.MeasureValidatorEnforcementOp(context.TODO(), d)
+				r.MeasureValidatorEnforcementOp(context.TODO(), d)
+				d.Decision = "allowed"
+				r.MeasureValidatorEnforcementOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_validator_enforcement_decisions_total The total number of decisions made by the enforcement validators.`,
+				`# TYPE kubewebhook_validator_enforcement_decisions_total counter`,
+				`kubewebhook_validator_enforcement_decisions_total{decision="allowed",enforcement_mode="warn",validator="test-policy"} 1`,
+				`kubewebhook_validator_enforcement_decisions_total{decision="warned",enforcement_mode="warn",validator="test-policy"} 2`,
+			},
+		},
+
+		"Measure canary reviews.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureCanaryOpData{CanaryName: "test-canary", WebhookType: "validating", Variant: "canary", Success: true, Allowed: false}
+				r.MeasureCanaryOp(context.TODO(), d)
+				r.MeasureCanaryOp(context.TODO(), d)
+				d.Variant = "stable"
+				d.Allowed = true
+				r.MeasureCanaryOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_webhook_canary_reviews_total The total number of reviews handled by the stable and canary variants of a canary rollout.`,
+				`# TYPE kubewebhook_webhook_canary_reviews_total counter`,
+				`kubewebhook_webhook_canary_reviews_total{allowed="false",canary="test-canary",success="true",variant="canary",webhook_type="validating"} 2`,
+				`kubewebhook_webhook_canary_reviews_total{allowed="true",canary="test-canary",success="true",variant="stable",webhook_type="validating"} 1`,
+			},
+		},
+
+		"Measure step timeouts.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureStepTimeoutOpData{StepName: "test-step", WebhookType: "mutating", TimeoutPolicy: "skip"}
+				r.MeasureStepTimeoutOp(context.TODO(), d)
+				r.MeasureStepTimeoutOp(context.TODO(), d)
+				d.TimeoutPolicy = "deny"
+				r.MeasureStepTimeoutOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_webhook_step_timeouts_total The total number of timed out webhook steps (mutators and validators).`,
+				`# TYPE kubewebhook_webhook_step_timeouts_total counter`,
+				`kubewebhook_webhook_step_timeouts_total{step="test-step",timeout_policy="deny",webhook_type="mutating"} 1`,
+				`kubewebhook_webhook_step_timeouts_total{step="test-step",timeout_policy="skip",webhook_type="mutating"} 2`,
+			},
+		},
+
+		"Measure dependency circuit breaker state changes.": {
+			measure: func(r *metrics.Recorder) {
+				d := webhook.MeasureDependencyCircuitBreakerOpData{Dependency: "test-dep", State: "open"}
+				r.MeasureDependencyCircuitBreakerOp(context.TODO(), d)
+				d.State = "half-open"
+				r.MeasureDependencyCircuitBreakerOp(context.TODO(), d)
+				d.State = "open"
+				r.MeasureDependencyCircuitBreakerOp(context.TODO(), d)
+			},
+			expMetrics: []string{
+				`# HELP kubewebhook_dependency_circuit_breaker_state The current state of the dependencies circuit breakers (1 on the current state, 0 on the rest).`,
+				`# TYPE kubewebhook_dependency_circuit_breaker_state gauge`,
+				`kubewebhook_dependency_circuit_breaker_state{dependency="test-dep",state="closed"} 0`,
+				`kubewebhook_dependency_circuit_breaker_state{dependency="test-dep",state="half-open"} 0`,
+				`kubewebhook_dependency_circuit_breaker_state{dependency="test-dep",state="open"} 1`,
+
+				`# HELP kubewebhook_dependency_circuit_breaker_state_changes_total The total number of state changes of the dependencies circuit breakers, by the new state.`,
+				`# TYPE kubewebhook_dependency_circuit_breaker_state_changes_total counter`,
+				`kubewebhook_dependency_circuit_breaker_state_changes_total{dependency="test-dep",state="half-open"} 1`,
+				`kubewebhook_dependency_circuit_breaker_state_changes_total{dependency="test-dep",state="open"} 2`,
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -1,5 +1,9 @@
 package model
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // AdmissionResponse is the interface type that all the different
 // types of webhooks must satisfy.
 type AdmissionResponse interface {
@@ -14,6 +18,14 @@ type ValidatingAdmissionResponse struct {
 	Allowed  bool
 	Message  string
 	Warnings []string
+	// Code is the status code used when not allowed, if not set it will be 400 (Bad Request).
+	Code int32
+	// Reason is the status reason used when not allowed.
+	Reason metav1.StatusReason
+	// Causes are the status causes used when not allowed (e.g: field errors).
+	Causes []metav1.StatusCause
+	// AuditAnnotations are the annotations that will be added to the apiserver audit log.
+	AuditAnnotations map[string]string
 }
 
 // MutatingAdmissionResponse is the response for mutating webhooks.
@@ -23,6 +35,27 @@ type MutatingAdmissionResponse struct {
 	ID             string
 	JSONPatchPatch []byte
 	Warnings       []string
+	// AuditAnnotations are the annotations that will be added to the apiserver audit log.
+	AuditAnnotations map[string]string
+	// Denied will deny the request instead of mutating it, the JSON patch will be ignored.
+	Denied bool
+	// Message is the status message used when denied.
+	Message string
+	// Code is the status code used when denied, if not set it will be 400 (Bad Request).
+	Code int32
+	// Reason is the status reason used when denied.
+	Reason metav1.StatusReason
+	// MutatorPatches are the JSON patches applied by each of the mutators, only set when the
+	// mutator chain has patch attribution enabled. These are not sent to the apiserver.
+	MutatorPatches []MutatorPatch
+}
+
+// MutatorPatch is the JSON patch applied by a single mutator of a mutator chain.
+type MutatorPatch struct {
+	// Mutator is the name of the mutator.
+	Mutator string
+	// JSONPatchPatch is the JSON patch applied by the mutator.
+	JSONPatchPatch []byte
 }
 
 // Helper type to satisfy the AdmissionResponse sealed interface.
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/json"
+
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -39,17 +41,36 @@ const (
 type AdmissionReview struct {
 	OriginalAdmissionReview runtime.Object
 
-	ID           string
-	Name         string
-	Namespace    string
-	Operation    AdmissionReviewOp
-	Version      AdmissionReviewVersion
-	RequestGVR   *metav1.GroupVersionResource
-	RequestGVK   *metav1.GroupVersionKind
-	OldObjectRaw []byte
-	NewObjectRaw []byte
-	DryRun       bool
-	UserInfo     authenticationv1.UserInfo
+	ID        string
+	Name      string
+	Namespace string
+	Operation AdmissionReviewOp
+	Version   AdmissionReviewVersion
+	// GVR is the resource being requested, if the request has been converted by the apiserver (e.g: `matchPolicy: Equivalent`),
+	// this will be the converted resource sent to the webhook.
+	GVR *metav1.GroupVersionResource
+	// GVK is the kind of the object being submitted, if the request has been converted by the apiserver
+	// (e.g: `matchPolicy: Equivalent`), this will be the converted kind sent to the webhook.
+	GVK *metav1.GroupVersionKind
+	// SubResource is the subresource being requested, if any (e.g: `status`, `scale`).
+	SubResource string
+	// RequestGVR is the original resource of the request, if not set by the apiserver it will be the same as GVR.
+	RequestGVR *metav1.GroupVersionResource
+	// RequestGVK is the original kind of the request, if not set by the apiserver it will be the same as GVK.
+	RequestGVK *metav1.GroupVersionKind
+	// RequestSubResource is the original subresource of the request, if not set by the apiserver it will be
+	// the same as SubResource.
+	RequestSubResource string
+	OldObjectRaw       []byte
+	NewObjectRaw       []byte
+	// Options are the decoded operation options, `*metav1.CreateOptions`, `*metav1.UpdateOptions` or
+	// `*metav1.DeleteOptions` depending on the operation. On the rest of the cases (e.g connect operation)
+	// or if the options can't be decoded, it will be nil, use OptionsRaw instead.
+	Options runtime.Object
+	// OptionsRaw are the raw operation options.
+	OptionsRaw []byte
+	DryRun     bool
+	UserInfo   authenticationv1.UserInfo
 }
 
 // NewAdmissionReviewV1Beta1 returns a new AdmissionReview from a admission/v1beta/admissionReview.
@@ -60,22 +81,41 @@ func NewAdmissionReviewV1Beta1(ar *admissionv1beta1.AdmissionReview) AdmissionRe
 		dryRun = *ar.Request.DryRun
 	}
 
+	op := v1Beta1OperationToModel(ar.Request.Operation)
+	gvr := ar.Request.Resource
+	gvk := ar.Request.Kind
+
 	return AdmissionReview{
 		OriginalAdmissionReview: ar,
 		ID:                      string(ar.Request.UID),
 		Name:                    ar.Request.Name,
 		Version:                 AdmissionReviewVersionV1beta1,
 		Namespace:               ar.Request.Namespace,
-		Operation:               v1Beta1OperationToModel(ar.Request.Operation),
+		Operation:               op,
 		OldObjectRaw:            ar.Request.OldObject.Raw,
 		NewObjectRaw:            ar.Request.Object.Raw,
+		GVR:                     &gvr,
+		GVK:                     &gvk,
+		SubResource:             ar.Request.SubResource,
 		RequestGVR:              v1Beta1ResourceToModel(ar),
 		RequestGVK:              v1Beta1KindToModel(ar),
+		RequestSubResource:      v1Beta1SubResourceToModel(ar),
+		Options:                 optionsToModel(op, ar.Request.Options.Raw),
+		OptionsRaw:              ar.Request.Options.Raw,
 		UserInfo:                ar.Request.UserInfo,
 		DryRun:                  dryRun,
 	}
 }
 
+func v1Beta1SubResourceToModel(ar *admissionv1beta1.AdmissionReview) string {
+	// If the apiserver doesn't set the original request data, the request is the same.
+	if ar.Request.RequestResource == nil {
+		return ar.Request.SubResource
+	}
+
+	return ar.Request.RequestSubResource
+}
+
 func v1Beta1ResourceToModel(ar *admissionv1beta1.AdmissionReview) *metav1.GroupVersionResource {
 	if ar.Request.RequestResource != nil {
 		return ar.Request.RequestResource
@@ -123,22 +163,41 @@ func NewAdmissionReviewV1(ar *admissionv1.AdmissionReview) AdmissionReview {
 		dryRun = *ar.Request.DryRun
 	}
 
+	op := v1OperationToModel(ar.Request.Operation)
+	gvr := ar.Request.Resource
+	gvk := ar.Request.Kind
+
 	return AdmissionReview{
 		OriginalAdmissionReview: ar,
 		ID:                      string(ar.Request.UID),
 		Name:                    ar.Request.Name,
 		Namespace:               ar.Request.Namespace,
 		Version:                 AdmissionReviewVersionV1,
-		Operation:               v1OperationToModel(ar.Request.Operation),
+		Operation:               op,
 		OldObjectRaw:            ar.Request.OldObject.Raw,
 		NewObjectRaw:            ar.Request.Object.Raw,
+		GVR:                     &gvr,
+		GVK:                     &gvk,
+		SubResource:             ar.Request.SubResource,
 		RequestGVR:              v1ResourceToModel(ar),
 		RequestGVK:              v1KindToModel(ar),
+		RequestSubResource:      v1SubResourceToModel(ar),
+		Options:                 optionsToModel(op, ar.Request.Options.Raw),
+		OptionsRaw:              ar.Request.Options.Raw,
 		UserInfo:                ar.Request.UserInfo,
 		DryRun:                  dryRun,
 	}
 }
 
+func v1SubResourceToModel(ar *admissionv1.AdmissionReview) string {
+	// If the apiserver doesn't set the original request data, the request is the same.
+	if ar.Request.RequestResource == nil {
+		return ar.Request.SubResource
+	}
+
+	return ar.Request.RequestSubResource
+}
+
 func v1ResourceToModel(ar *admissionv1.AdmissionReview) *metav1.GroupVersionResource {
 	if ar.Request.RequestResource != nil {
 		return ar.Request.RequestResource
@@ -177,3 +236,28 @@ func v1OperationToModel(op admissionv1.Operation) AdmissionReviewOp {
 
 	return OperationUnknown
 }
+
+// optionsToModel decodes the raw options of the operation into the typed Kubernetes options.
+func optionsToModel(op AdmissionReviewOp, raw []byte) runtime.Object {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var opts runtime.Object
+	switch op {
+	case OperationCreate:
+		opts = &metav1.CreateOptions{}
+	case OperationUpdate:
+		opts = &metav1.UpdateOptions{}
+	case OperationDelete:
+		opts = &metav1.DeleteOptions{}
+	default:
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, opts); err != nil {
+		return nil
+	}
+
+	return opts
+}
@@ -75,6 +75,8 @@ func getBaseModelV1Beta1() model.AdmissionReview {
 		UserInfo:                authenticationv1.UserInfo{},
 		OldObjectRaw:            []byte("old-raw-thingy"),
 		NewObjectRaw:            []byte("raw-thingy"),
+		GVR:                     &metav1.GroupVersionResource{Group: "core", Resource: "pods", Version: "v1"},
+		GVK:                     &metav1.GroupVersionKind{Group: "core", Kind: "Pod", Version: "v1"},
 		RequestGVR:              &metav1.GroupVersionResource{Group: "core", Resource: "pods", Version: "v1"},
 		RequestGVK:              &metav1.GroupVersionKind{Group: "core", Kind: "Pod", Version: "v1"},
 		DryRun:                  true,
@@ -92,6 +94,8 @@ func getBaseModelV1() model.AdmissionReview {
 		UserInfo:                authenticationv1.UserInfo{},
 		OldObjectRaw:            []byte("old-raw-thingy"),
 		NewObjectRaw:            []byte("raw-thingy"),
+		GVR:                     &metav1.GroupVersionResource{Group: "core", Resource: "pods", Version: "v1"},
+		GVK:                     &metav1.GroupVersionKind{Group: "core", Kind: "Pod", Version: "v1"},
 		RequestGVR:              &metav1.GroupVersionResource{Group: "core", Resource: "pods", Version: "v1"},
 		RequestGVK:              &metav1.GroupVersionKind{Group: "core", Kind: "Pod", Version: "v1"},
 		DryRun:                  true,
@@ -193,6 +197,145 @@ func TestNewAdmissionReviewV1Beta1(t *testing.T) {
 				return m
 			},
 		},
+
+		"Converted Kubernetes object with subresource to model.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Kind = metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				o.Request.Resource = metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				o.Request.SubResource = "scale"
+				o.Request.RequestKind = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				o.Request.RequestResource = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				o.Request.RequestSubResource = "scale"
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Kind = metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				o.Request.Resource = metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				o.Request.SubResource = "scale"
+				o.Request.RequestKind = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				o.Request.RequestResource = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				o.Request.RequestSubResource = "scale"
+
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.GVK = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				m.GVR = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				m.SubResource = "scale"
+				m.RequestGVK = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				m.RequestGVR = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				m.RequestSubResource = "scale"
+				return m
+			},
+		},
+
+		"Kubernetes object without optional Request subresource should use the subresource.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.SubResource = "status"
+				o.Request.RequestResource = nil
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.SubResource = "status"
+				o.Request.RequestResource = nil
+
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.SubResource = "status"
+				m.RequestSubResource = "status"
+				return m
+			},
+		},
+
+		"Kubernetes object with create options should decode the options.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.OptionsRaw = []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)
+				m.Options = &metav1.CreateOptions{
+					TypeMeta:     metav1.TypeMeta{Kind: "CreateOptions", APIVersion: "meta.k8s.io/v1"},
+					FieldManager: "kubectl",
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with update options should decode the options.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Operation = admissionv1beta1.Update
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Operation = admissionv1beta1.Update
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.Operation = model.OperationUpdate
+				m.OptionsRaw = []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)
+				m.Options = &metav1.UpdateOptions{
+					TypeMeta:     metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: "meta.k8s.io/v1"},
+					FieldManager: "kubectl",
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with delete options should decode the options.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Operation = admissionv1beta1.Delete
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Operation = admissionv1beta1.Delete
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)}
+
+				fg := metav1.DeletePropagationForeground
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.Operation = model.OperationDelete
+				m.OptionsRaw = []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)
+				m.Options = &metav1.DeleteOptions{
+					TypeMeta:          metav1.TypeMeta{Kind: "DeleteOptions", APIVersion: "meta.k8s.io/v1"},
+					PropagationPolicy: &fg,
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with invalid options shouldn't decode the options.": {
+			ar: func() *admissionv1beta1.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1Beta1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{`)}
+
+				m := getBaseModelV1Beta1()
+				m.OriginalAdmissionReview = o
+				m.OptionsRaw = []byte(`{`)
+				return m
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -298,6 +441,145 @@ func TestNewAdmissionReviewV1(t *testing.T) {
 				return m
 			},
 		},
+
+		"Converted Kubernetes object with subresource to model.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Kind = metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				o.Request.Resource = metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				o.Request.SubResource = "scale"
+				o.Request.RequestKind = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				o.Request.RequestResource = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				o.Request.RequestSubResource = "scale"
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Kind = metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				o.Request.Resource = metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				o.Request.SubResource = "scale"
+				o.Request.RequestKind = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				o.Request.RequestResource = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				o.Request.RequestSubResource = "scale"
+
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.GVK = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1"}
+				m.GVR = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1"}
+				m.SubResource = "scale"
+				m.RequestGVK = &metav1.GroupVersionKind{Group: "apps", Kind: "Scale", Version: "v1beta2"}
+				m.RequestGVR = &metav1.GroupVersionResource{Group: "apps", Resource: "deployments", Version: "v1beta2"}
+				m.RequestSubResource = "scale"
+				return m
+			},
+		},
+
+		"Kubernetes object without optional Request subresource should use the subresource.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.SubResource = "status"
+				o.Request.RequestResource = nil
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.SubResource = "status"
+				o.Request.RequestResource = nil
+
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.SubResource = "status"
+				m.RequestSubResource = "status"
+				return m
+			},
+		},
+
+		"Kubernetes object with create options should decode the options.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.OptionsRaw = []byte(`{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)
+				m.Options = &metav1.CreateOptions{
+					TypeMeta:     metav1.TypeMeta{Kind: "CreateOptions", APIVersion: "meta.k8s.io/v1"},
+					FieldManager: "kubectl",
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with update options should decode the options.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Operation = admissionv1.Update
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Operation = admissionv1.Update
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)}
+
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.Operation = model.OperationUpdate
+				m.OptionsRaw = []byte(`{"kind":"UpdateOptions","apiVersion":"meta.k8s.io/v1","fieldManager":"kubectl"}`)
+				m.Options = &metav1.UpdateOptions{
+					TypeMeta:     metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: "meta.k8s.io/v1"},
+					FieldManager: "kubectl",
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with delete options should decode the options.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Operation = admissionv1.Delete
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Operation = admissionv1.Delete
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)}
+
+				fg := metav1.DeletePropagationForeground
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.Operation = model.OperationDelete
+				m.OptionsRaw = []byte(`{"kind":"DeleteOptions","apiVersion":"meta.k8s.io/v1","propagationPolicy":"Foreground"}`)
+				m.Options = &metav1.DeleteOptions{
+					TypeMeta:          metav1.TypeMeta{Kind: "DeleteOptions", APIVersion: "meta.k8s.io/v1"},
+					PropagationPolicy: &fg,
+				}
+				return m
+			},
+		},
+
+		"Kubernetes object with invalid options shouldn't decode the options.": {
+			ar: func() *admissionv1.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{`)}
+				return o
+			},
+			expModel: func() model.AdmissionReview {
+				o := getBaseARV1()
+				o.Request.Options = runtime.RawExtension{Raw: []byte(`{`)}
+
+				m := getBaseModelV1()
+				m.OriginalAdmissionReview = o
+				m.OptionsRaw = []byte(`{`)
+				return m
+			},
+		},
 	}
 
 	for name, test := range tests {
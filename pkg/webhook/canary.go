@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+)
+
+// Canary variants.
+const (
+	CanaryVariantStable = "stable"
+	CanaryVariantCanary = "canary"
+)
+
+// CanarySelector selects the requests that will use the canary implementation on a canary
+// rollout (e.g: `mutating.NewCanaryMutator`, `validating.NewCanaryValidator`).
+//
+// The selection is deterministic, the same object (namespace and name) will always be selected
+// in the same way.
+type CanarySelector struct {
+	// Percent is the percentage (0-100) of the objects that will be selected, based on the hash of
+	// the object namespace and name (or generate name if missing).
+	Percent int
+	// Namespaces are the namespaces whose objects will always be selected.
+	Namespaces []string
+}
+
+// Validate validates the selector.
+func (c CanarySelector) Validate() error {
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+
+	return nil
+}
+
+// Select returns true if the request must use the canary implementation.
+func (c CanarySelector) Select(ar *model.AdmissionReview, obj metav1.Object) bool {
+	for _, ns := range c.Namespaces {
+		if ns == ar.Namespace {
+			return true
+		}
+	}
+
+	switch c.Percent {
+	case 0:
+		return false
+	case 100:
+		return true
+	}
+
+	name := ar.Name
+	if obj != nil {
+		if name == "" {
+			name = obj.GetName()
+		}
+		if name == "" {
+			name = obj.GetGenerateName()
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(ar.Namespace + "/" + name))
+	return int(h.Sum32()%100) < c.Percent
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type contextKey string
+
+// contextOldObjectKey used as unique key to store the decoded old object in the context.
+const contextOldObjectKey = contextKey("kubewebhook-old-object")
+
+// CtxWithOldObject returns a copy of parent in which the decoded old object of the
+// admission review has been stored.
+//
+// The mutating and validating webhooks set it on update operations, this can be used
+// to test mutators and validators that depend on it.
+func CtxWithOldObject(parent context.Context, obj metav1.Object) context.Context {
+	return context.WithValue(parent, contextOldObjectKey, obj)
+}
+
+// OldObjectFromCtx gets the decoded old object of the admission review from a context.
+// It will be decoded with the same type as the received object and is only available
+// on update operations (delete operations receive the old object as the object itself).
+func OldObjectFromCtx(ctx context.Context) (metav1.Object, bool) {
+	obj, ok := ctx.Value(contextOldObjectKey).(metav1.Object)
+	if !ok || obj == nil {
+		return nil, false
+	}
+
+	return obj, true
+}
+
+// TypedOldObjectFromCtx is the same as OldObjectFromCtx but returns the old object with the type
+// used by the typed webhooks (e.g: `*corev1.Pod`). If the old object is not present or is not of
+// the received type, it will return false.
+func TypedOldObjectFromCtx[T metav1.Object](ctx context.Context) (T, bool) {
+	obj, ok := OldObjectFromCtx(ctx)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	tObj, ok := obj.(T)
+	return tObj, ok
+}
@@ -0,0 +1,391 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+)
+
+// CircuitBreakerState is the state of a dependency circuit breaker.
+type CircuitBreakerState string
+
+const (
+	// CircuitBreakerStateClosed lets the calls reach the dependency.
+	CircuitBreakerStateClosed CircuitBreakerState = "closed"
+	// CircuitBreakerStateOpen rejects the calls without reaching the dependency.
+	CircuitBreakerStateOpen CircuitBreakerState = "open"
+	// CircuitBreakerStateHalfOpen lets a single probe call reach the dependency, to check
+	// if the dependency has recovered.
+	CircuitBreakerStateHalfOpen CircuitBreakerState = "half-open"
+)
+
+// CircuitOpenVerdict is the verdict of the calls rejected by an open circuit breaker.
+type CircuitOpenVerdict string
+
+const (
+	// CircuitOpenVerdictAllow will allow the request (fail open).
+	CircuitOpenVerdictAllow CircuitOpenVerdict = "allow"
+	// CircuitOpenVerdictDeny will deny the request (fail closed).
+	CircuitOpenVerdictDeny CircuitOpenVerdict = "deny"
+	// CircuitOpenVerdictError will fail the review with an error, the webhook failure policy will be applied.
+	CircuitOpenVerdictError CircuitOpenVerdict = "error"
+)
+
+// Validate validates the verdict.
+func (v CircuitOpenVerdict) Validate() error {
+	switch v {
+	case CircuitOpenVerdictAllow, CircuitOpenVerdictDeny, CircuitOpenVerdictError:
+		return nil
+	}
+
+	return fmt.Errorf("unknown circuit open verdict %q", v)
+}
+
+// ErrCircuitOpen is the error used when the dependency circuit breaker is open, it can be
+// checked with `errors.Is`.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is the error returned by the dependency calls rejected by an open circuit breaker.
+//
+// If it's returned by a validator or a mutator, the review will fail and the webhook failure policy will
+// be applied, use `validating.DependencyErrorResult` to map it to the configured verdict.
+type CircuitOpenError struct {
+	// Dependency is the name of the dependency.
+	Dependency string
+	// Verdict is the configured verdict of the dependency, validators can use it to allow or
+	// deny the request (e.g: `validating.DependencyErrorResult`).
+	Verdict CircuitOpenVerdict
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%q dependency %s", e.Dependency, ErrCircuitOpen)
+}
+
+// Is returns true when the target is `ErrCircuitOpen`.
+func (e *CircuitOpenError) Is(target error) bool { return target == ErrCircuitOpen }
+
+// DependencyConfig is the configuration of a dependency.
+type DependencyConfig struct {
+	// Name is the name of the dependency, used on the logs, traces and metrics.
+	Name string
+	// HTTPClient is the HTTP client used to call the dependency, it will be traced
+	// with the tracer (use `Dependency.HTTPClient`). By default `http.DefaultClient`.
+	HTTPClient *http.Client
+	// MaxAttempts is the maximum number of attempts of each call (the first one and the retries). By default 3.
+	MaxAttempts int
+	// AttemptTimeout is the maximum duration of each attempt. By default only limited by the context.
+	AttemptTimeout time.Duration
+	// BackoffInitial is the wait before the first retry, doubled on each retry (with jitter). By default 50ms.
+	// The retries that would wait beyond the context deadline (e.g the admission review deadline) are not made.
+	BackoffInitial time.Duration
+	// BackoffMax is the maximum wait between retries. By default 1s.
+	BackoffMax time.Duration
+	// IsRetriable returns true if the call failed with an error that can be retried. By default all the errors.
+	// Not retriable errors are returned directly and don't count as circuit breaker failures (e.g: bad request).
+	IsRetriable func(err error) bool
+	// FailureThreshold is the number of consecutive failed attempts that will open the circuit breaker. By default 5.
+	FailureThreshold int
+	// OpenDuration is the duration the circuit breaker is open before letting a probe call reach the
+	// dependency (half-open). By default 30s.
+	OpenDuration time.Duration
+	// OpenVerdict is the verdict of the calls rejected by the open circuit breaker. By default error.
+	OpenVerdict CircuitOpenVerdict
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+	// MetricsRecorder is used to measure the circuit breaker state changes. By default no-op.
+	MetricsRecorder MetricsRecorder
+}
+
+func (c *DependencyConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 3
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must be greater than 0")
+	}
+
+	if c.AttemptTimeout < 0 {
+		return fmt.Errorf("attempt timeout can't be negative")
+	}
+
+	if c.BackoffInitial == 0 {
+		c.BackoffInitial = 50 * time.Millisecond
+	}
+
+	if c.BackoffMax == 0 {
+		c.BackoffMax = time.Second
+	}
+
+	if c.BackoffInitial < 0 || c.BackoffMax < c.BackoffInitial {
+		return fmt.Errorf("invalid backoff, initial must be greater than 0 and lower than max")
+	}
+
+	if c.IsRetriable == nil {
+		c.IsRetriable = func(error) bool { return true }
+	}
+
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	}
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("failure threshold must be greater than 0")
+	}
+
+	if c.OpenDuration == 0 {
+		c.OpenDuration = 30 * time.Second
+	}
+	if c.OpenDuration < 0 {
+		return fmt.Errorf("open duration can't be negative")
+	}
+
+	if c.OpenVerdict == "" {
+		c.OpenVerdict = CircuitOpenVerdictError
+	}
+	if err := c.OpenVerdict.Validate(); err != nil {
+		return err
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"dependency": c.Name})
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+// Dependency wraps the calls to an external dependency of the webhooks (e.g: an HTTP service
+// used by a validator), retrying the failed calls with backoff inside the admission review
+// deadline, and using a circuit breaker to stop calling the dependency while it's failing.
+//
+// Dependencies are safe to be used concurrently, and they should be shared by all the reviews.
+type Dependency struct {
+	cfg        DependencyConfig
+	httpClient *http.Client
+	metricsRec DependencyCircuitBreakerMetricsRecorder
+	now        func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitBreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewDependency returns a new dependency.
+func NewDependency(cfg DependencyConfig) (*Dependency, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &Dependency{
+		cfg:        cfg,
+		httpClient: cfg.Tracer.TraceHTTPClient(cfg.Name, cfg.HTTPClient),
+		metricsRec: NewFullMetricsRecorder(cfg.MetricsRecorder),
+		now:        time.Now,
+		state:      CircuitBreakerStateClosed,
+	}, nil
+}
+
+// HTTPClient returns the traced HTTP client of the dependency, to be used inside the calls.
+//
+// Note: To trace correctly from the parent traces, the requests should have the call context set.
+func (d *Dependency) HTTPClient() *http.Client { return d.httpClient }
+
+// State returns the current state of the circuit breaker.
+func (d *Dependency) State() CircuitBreakerState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// Call calls the dependency using f, retrying the failed attempts. If the circuit breaker is open, f will not
+// be called and a `*CircuitOpenError` will be returned. If f panics, the attempt counts as failed and the panic
+// is propagated.
+func (d *Dependency) Call(ctx context.Context, f func(ctx context.Context) error) error {
+	logger := d.cfg.Logger.WithCtxValues(ctx)
+
+	var lastErr error
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		if !d.allow(ctx) {
+			if lastErr != nil {
+				return fmt.Errorf("%q dependency call failed after %d attempts: %w", d.cfg.Name, attempt-1, lastErr)
+			}
+			return &CircuitOpenError{Dependency: d.cfg.Name, Verdict: d.cfg.OpenVerdict}
+		}
+
+		err := d.attempt(ctx, f)
+		if err == nil {
+			d.success(ctx)
+			return nil
+		}
+		lastErr = err
+
+		// Canceled by the caller, the dependency is not failing.
+		if ctx.Err() != nil && errors.Is(ctx.Err(), context.Canceled) {
+			d.release()
+			return fmt.Errorf("%q dependency call canceled: %w", d.cfg.Name, err)
+		}
+
+		if !d.cfg.IsRetriable(err) {
+			d.release()
+			return fmt.Errorf("%q dependency call failed: %w", d.cfg.Name, err)
+		}
+
+		d.failure(ctx)
+		logger.Warningf("Dependency call attempt %d failed: %s", attempt, err)
+		d.cfg.Tracer.AddTraceEvent(ctx, "dependency call failed", map[string]interface{}{"dependency": d.cfg.Name, "attempt": attempt, "error": err.Error()})
+
+		if attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		// Don't retry if we can't wait for the retry inside the deadline.
+		wait := d.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			logger.Debugf("Dependency call retry skipped, backoff %s exceeds the deadline", wait)
+			return fmt.Errorf("%q dependency call failed after %d attempts, no time left to retry: %w", d.cfg.Name, attempt, err)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%q dependency call failed after %d attempts, context done: %w", d.cfg.Name, attempt, err)
+		case <-t.C:
+		}
+	}
+
+	return fmt.Errorf("%q dependency call failed after %d attempts: %w", d.cfg.Name, d.cfg.MaxAttempts, lastErr)
+}
+
+func (d *Dependency) attempt(ctx context.Context, f func(ctx context.Context) error) error {
+	// A panicking call counts as a failure, this way the half-open probe is not kept forever.
+	defer func() {
+		if r := recover(); r != nil {
+			d.failure(ctx)
+			panic(r)
+		}
+	}()
+
+	if d.cfg.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.cfg.AttemptTimeout)
+		defer cancel()
+	}
+
+	return f(ctx)
+}
+
+// backoff returns the exponential backoff wait with equal jitter, for the retry of the attempt.
+func (d *Dependency) backoff(attempt int) time.Duration {
+	wait := d.cfg.BackoffInitial
+	for i := 1; i < attempt && wait < d.cfg.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.BackoffMax {
+		wait = d.cfg.BackoffMax
+	}
+
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// allow returns true if the call can reach the dependency, moving the circuit breaker to
+// half-open when the open duration has passed.
+func (d *Dependency) allow(ctx context.Context) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state == CircuitBreakerStateOpen && d.now().Sub(d.openedAt) >= d.cfg.OpenDuration {
+		d.state = CircuitBreakerStateHalfOpen
+		d.stateChanged(ctx, CircuitBreakerStateHalfOpen)
+	}
+
+	switch d.state {
+	case CircuitBreakerStateOpen:
+		return false
+	case CircuitBreakerStateHalfOpen:
+		// Only one probe at a time.
+		allowed := !d.probing
+		d.probing = true
+		return allowed
+	}
+
+	return true
+}
+
+func (d *Dependency) success(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	changed := d.state != CircuitBreakerStateClosed
+	d.state = CircuitBreakerStateClosed
+	d.failures = 0
+	d.probing = false
+
+	if changed {
+		d.stateChanged(ctx, CircuitBreakerStateClosed)
+	}
+}
+
+func (d *Dependency) failure(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.failures++
+	d.probing = false
+	if d.state == CircuitBreakerStateHalfOpen || (d.state == CircuitBreakerStateClosed && d.failures >= d.cfg.FailureThreshold) {
+		d.state = CircuitBreakerStateOpen
+		d.openedAt = d.now()
+		d.stateChanged(ctx, CircuitBreakerStateOpen)
+	}
+}
+
+// release releases the half-open probe of a call that didn't succeed or fail.
+func (d *Dependency) release() {
+	d.mu.Lock()
+	d.probing = false
+	d.mu.Unlock()
+}
+
+// stateChanged notifies the circuit breaker state changes, it must be called with the lock held,
+// this way the notifications are made in the same order as the state changes.
+func (d *Dependency) stateChanged(ctx context.Context, state CircuitBreakerState) {
+	logger := d.cfg.Logger.WithCtxValues(ctx)
+	if state == CircuitBreakerStateOpen {
+		logger.Warningf("Dependency circuit breaker opened for %s", d.cfg.OpenDuration)
+	} else {
+		logger.Infof("Dependency circuit breaker state changed to %s", state)
+	}
+	d.cfg.Tracer.AddTraceEvent(ctx, "dependency circuit breaker state changed", map[string]interface{}{"dependency": d.cfg.Name, "state": string(state)})
+	d.metricsRec.MeasureDependencyCircuitBreakerOp(ctx, MeasureDependencyCircuitBreakerOpData{
+		Dependency: d.cfg.Name,
+		State:      string(state),
+	})
+}
@@ -0,0 +1,295 @@
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestDependencyCall(t *testing.T) {
+	errFailed := errors.New("failed")
+	errNotRetriable := errors.New("not retriable")
+
+	tests := map[string]struct {
+		cfg         webhook.DependencyConfig
+		ctx         func() (context.Context, context.CancelFunc)
+		results     []error
+		expAttempts int
+		expStates   []string
+		expErr      bool
+	}{
+		"A successful call should be called once.": {
+			cfg:         webhook.DependencyConfig{},
+			results:     []error{nil},
+			expAttempts: 1,
+		},
+
+		"A failed call should be retried until it succeeds.": {
+			cfg:         webhook.DependencyConfig{MaxAttempts: 3, BackoffInitial: time.Millisecond, BackoffMax: time.Millisecond},
+			results:     []error{errFailed, errFailed, nil},
+			expAttempts: 3,
+		},
+
+		"A failed call should be retried until the max attempts.": {
+			cfg:         webhook.DependencyConfig{MaxAttempts: 3, BackoffInitial: time.Millisecond, BackoffMax: time.Millisecond},
+			results:     []error{errFailed, errFailed, errFailed},
+			expAttempts: 3,
+			expErr:      true,
+		},
+
+		"A not retriable error should not be retried.": {
+			cfg: webhook.DependencyConfig{
+				MaxAttempts: 3,
+				IsRetriable: func(err error) bool { return !errors.Is(err, errNotRetriable) },
+			},
+			results:     []error{errNotRetriable},
+			expAttempts: 1,
+			expErr:      true,
+		},
+
+		"A failed call should not be retried if the backoff exceeds the deadline.": {
+			cfg: webhook.DependencyConfig{MaxAttempts: 3, BackoffInitial: time.Second, BackoffMax: time.Second},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+			results:     []error{errFailed},
+			expAttempts: 1,
+			expErr:      true,
+		},
+
+		"Consecutive failures reaching the threshold should open the circuit breaker and stop the retries.": {
+			cfg:         webhook.DependencyConfig{MaxAttempts: 5, FailureThreshold: 2, BackoffInitial: time.Millisecond, BackoffMax: time.Millisecond},
+			results:     []error{errFailed, errFailed},
+			expAttempts: 2,
+			expStates:   []string{"open"},
+			expErr:      true,
+		},
+
+		"Not retriable errors should not open the circuit breaker.": {
+			cfg: webhook.DependencyConfig{
+				MaxAttempts:      1,
+				FailureThreshold: 1,
+				IsRetriable:      func(err error) bool { return !errors.Is(err, errNotRetriable) },
+			},
+			results:     []error{errNotRetriable},
+			expAttempts: 1,
+			expErr:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			for _, state := range test.expStates {
+				mrec.On("MeasureDependencyCircuitBreakerOp", mock.Anything, webhook.MeasureDependencyCircuitBreakerOpData{
+					Dependency: "test",
+					State:      state,
+				}).Once().Return()
+			}
+
+			test.cfg.Name = "test"
+			test.cfg.MetricsRecorder = mrec
+			dep, err := webhook.NewDependency(test.cfg)
+			require.NoError(err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.ctx != nil {
+				ctx, cancel = test.ctx()
+			}
+			defer cancel()
+
+			attempts := 0
+			err = dep.Call(ctx, func(_ context.Context) error {
+				if attempts >= len(test.results) {
+					return fmt.Errorf("unexpected attempt")
+				}
+				err := test.results[attempts]
+				attempts++
+				return err
+			})
+
+			assert.Equal(test.expAttempts, attempts)
+			if test.expErr {
+				assert.Error(err)
+				assert.False(errors.Is(err, webhook.ErrCircuitOpen))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestDependencyCircuitBreaker(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := map[string]struct {
+		probeResult error
+		expStates   []string
+		expState    webhook.CircuitBreakerState
+	}{
+		"A successful probe should close the circuit breaker.": {
+			probeResult: nil,
+			expStates:   []string{"open", "half-open", "closed"},
+			expState:    webhook.CircuitBreakerStateClosed,
+		},
+
+		"A failed probe should open again the circuit breaker.": {
+			probeResult: errFailed,
+			expStates:   []string{"open", "half-open", "open"},
+			expState:    webhook.CircuitBreakerStateOpen,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			var gotStates []string
+			mrec.On("MeasureDependencyCircuitBreakerOp", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				gotStates = append(gotStates, args.Get(1).(webhook.MeasureDependencyCircuitBreakerOpData).State)
+			}).Return()
+
+			now := time.Now()
+			dep, err := webhook.NewDependency(webhook.DependencyConfig{
+				Name:             "test",
+				MaxAttempts:      1,
+				FailureThreshold: 1,
+				OpenDuration:     30 * time.Second,
+				OpenVerdict:      webhook.CircuitOpenVerdictDeny,
+				MetricsRecorder:  mrec,
+			})
+			require.NoError(err)
+			webhook.SetDependencyClock(dep, func() time.Time { return now })
+
+			// Open the circuit breaker.
+			err = dep.Call(context.TODO(), func(_ context.Context) error { return errFailed })
+			require.ErrorIs(err, errFailed)
+			require.Equal(webhook.CircuitBreakerStateOpen, dep.State())
+
+			// Calls are rejected while open.
+			called := false
+			err = dep.Call(context.TODO(), func(_ context.Context) error { called = true; return nil })
+			assert.False(called)
+			var coErr *webhook.CircuitOpenError
+			if assert.True(errors.As(err, &coErr)) {
+				assert.True(errors.Is(err, webhook.ErrCircuitOpen))
+				assert.Equal("test", coErr.Dependency)
+				assert.Equal(webhook.CircuitOpenVerdictDeny, coErr.Verdict)
+			}
+
+			// After the open duration, a probe reaches the dependency.
+			now = now.Add(30 * time.Second)
+			called = false
+			_ = dep.Call(context.TODO(), func(_ context.Context) error { called = true; return test.probeResult })
+			assert.True(called)
+
+			assert.Equal(test.expState, dep.State())
+			assert.Equal(test.expStates, gotStates)
+		})
+	}
+}
+
+func TestDependencyCircuitBreakerProbePanic(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	dep, err := webhook.NewDependency(webhook.DependencyConfig{
+		Name:             "test",
+		MaxAttempts:      1,
+		FailureThreshold: 1,
+		OpenDuration:     30 * time.Second,
+	})
+	require.NoError(err)
+	webhook.SetDependencyClock(dep, func() time.Time { return now })
+
+	// Open the circuit breaker.
+	err = dep.Call(context.TODO(), func(_ context.Context) error { return errors.New("failed") })
+	require.Error(err)
+	require.Equal(webhook.CircuitBreakerStateOpen, dep.State())
+
+	// A panicking probe should open again the circuit breaker and keep panicking.
+	now = now.Add(30 * time.Second)
+	assert.PanicsWithValue("wanted", func() {
+		_ = dep.Call(context.TODO(), func(_ context.Context) error { panic("wanted") })
+	})
+	require.Equal(webhook.CircuitBreakerStateOpen, dep.State())
+
+	// After the open duration, a new probe reaches the dependency.
+	now = now.Add(30 * time.Second)
+	called := false
+	err = dep.Call(context.TODO(), func(_ context.Context) error { called = true; return nil })
+	assert.NoError(err)
+	assert.True(called)
+	assert.Equal(webhook.CircuitBreakerStateClosed, dep.State())
+}
+
+func TestDependencyCircuitBreakerConcurrentMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var (
+		mu        sync.Mutex
+		lastState = string(webhook.CircuitBreakerStateClosed)
+	)
+	mrec := webhookmock.NewFullMetricsRecorder(t)
+	mrec.On("MeasureDependencyCircuitBreakerOp", mock.Anything, mock.Anything).Maybe().Run(func(args mock.Arguments) {
+		// Slow recorder, to interleave the concurrent measurements.
+		time.Sleep(time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		lastState = args.Get(1).(webhook.MeasureDependencyCircuitBreakerOpData).State
+	}).Return()
+
+	dep, err := webhook.NewDependency(webhook.DependencyConfig{
+		Name:             "test",
+		MaxAttempts:      1,
+		FailureThreshold: 1,
+		OpenDuration:     30 * time.Second,
+		MetricsRecorder:  mrec,
+	})
+	require.NoError(err)
+
+	// Every time the clock is checked the open duration has passed.
+	var ticks int64
+	t0 := time.Now()
+	webhook.SetDependencyClock(dep, func() time.Time {
+		return t0.Add(time.Duration(atomic.AddInt64(&ticks, 1)) * 30 * time.Second)
+	})
+
+	// Execute successful and failed calls concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = dep.Call(context.TODO(), func(_ context.Context) error {
+				if i%2 == 0 {
+					return errors.New("failed")
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(string(dep.State()), lastState)
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AdmissionError is an error that mutators and validators can return (directly or wrapped)
+// to set the code, reason and causes of the status returned to the apiserver when
+// the admission review fails.
+//
+// Only this error is returned to the user, other errors with status (e.g Kubernetes API errors)
+// are handled as regular errors, wrap them with an admission error to return them.
+//
+// It satisfies Kubernetes `k8s.io/apimachinery/pkg/api/errors.APIStatus` interface.
+type AdmissionError struct {
+	// Message is the message of the status, if empty, the wrapped error message will be used.
+	Message string
+	// Code is the HTTP status code of the status.
+	Code int32
+	// Reason is the machine readable reason of the status.
+	Reason metav1.StatusReason
+	// Causes are the specific causes of the error (e.g: invalid fields).
+	Causes []metav1.StatusCause
+	// Err is the optional wrapped error.
+	Err error
+}
+
+func (e *AdmissionError) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.Reason != "":
+		return string(e.Reason)
+	}
+
+	return "admission error"
+}
+
+// Unwrap returns the wrapped error.
+func (e *AdmissionError) Unwrap() error { return e.Err }
+
+// Status returns the Kubernetes status of the error.
+func (e *AdmissionError) Status() metav1.Status {
+	status := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: e.Error(),
+		Reason:  e.Reason,
+		Code:    e.Code,
+	}
+
+	if len(e.Causes) > 0 {
+		status.Details = &metav1.StatusDetails{Causes: e.Causes}
+	}
+
+	return status
+}
+
+// InvalidObjectTypeError is the error returned by the typed webhooks, mutators and validators when
+// the received object is not of the expected type (e.g: the webhook is registered for the wrong resources).
+type InvalidObjectTypeError struct {
+	// Expected is the expected object type.
+	Expected string
+	// Got is the received object type.
+	Got string
+}
+
+func (e *InvalidObjectTypeError) Error() string {
+	return fmt.Sprintf("invalid object type, expected %q, got %q", e.Expected, e.Got)
+}
@@ -0,0 +1,6 @@
+package webhook
+
+import "time"
+
+// SetDependencyClock sets the clock used by the dependency circuit breaker.
+func SetDependencyClock(d *Dependency, now func() time.Time) { d.now = now }
@@ -1,14 +1,19 @@
 package helpers
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
 )
 
 // K8sObject represents a full Kubernetes object.
@@ -118,3 +123,115 @@ func (d dynamicObjectCreator) NewObject(raw []byte) (K8sObject, error) {
 
 	return obj, err
 }
+
+// NewTypedObject returns a new empty object of the received type, the type must be a pointer
+// to a Kubernetes object type (e.g: `*corev1.Pod`).
+func NewTypedObject[T metav1.Object]() (T, error) {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return zero, fmt.Errorf("type %T must be a pointer to a Kubernetes object struct", zero)
+	}
+
+	obj, ok := reflect.New(t.Elem()).Interface().(T)
+	if !ok {
+		return zero, fmt.Errorf("could not create new %T object", zero)
+	}
+
+	return obj, nil
+}
+
+// CheckObjectKind checks that the kind of the admission review object is the same kind as the
+// received typed object, if not it will return a `webhook.InvalidObjectTypeError`.
+//
+// If the object type is registered on the global client Scheme, the group and kind will be checked,
+// if not, only the kind will be checked based on the type name (Kubernetes convention). If the
+// admission review doesn't have the kind (e.g: tests), the kind of the decoded object will be used,
+// and if it's also missing, it will not be checked.
+func CheckObjectKind(obj metav1.Object, gvk *metav1.GroupVersionKind) error {
+	if gvk == nil || gvk.Kind == "" {
+		rObj, ok := obj.(runtime.Object)
+		if !ok {
+			return nil
+		}
+		objGVK := rObj.GetObjectKind().GroupVersionKind()
+		gvk = &metav1.GroupVersionKind{Group: objGVK.Group, Version: objGVK.Version, Kind: objGVK.Kind}
+	}
+
+	if gvk.Kind == "" {
+		return nil
+	}
+
+	expGroup, expKind, groupKnown := objectGroupKind(obj)
+	if gvk.Kind == expKind && (!groupKnown || gvk.Group == expGroup) {
+		return nil
+	}
+
+	expected := expKind
+	if groupKnown {
+		expected = groupKindString(expGroup, expKind)
+	}
+
+	return &webhook.InvalidObjectTypeError{
+		Expected: expected,
+		Got:      groupKindString(gvk.Group, gvk.Kind),
+	}
+}
+
+func objectGroupKind(obj metav1.Object) (group, kind string, groupKnown bool) {
+	if rObj, ok := obj.(runtime.Object); ok {
+		gvks, _, err := clientsetscheme.Scheme.ObjectKinds(rObj)
+		if err == nil && len(gvks) > 0 {
+			return gvks[0].Group, gvks[0].Kind, true
+		}
+	}
+
+	return "", getK8sObjType(obj).Name(), false
+}
+
+func groupKindString(group, kind string) string {
+	return strings.Trim(group+"/"+kind, "/")
+}
+
+// RunWithTimeout runs a function with a timeout, it returns the function result, or timedOut
+// if the timeout has been reached before the function finished. If the context received is done
+// before the timeout it will return the context error. The panics of the function are returned as errors.
+//
+// On timeouts, the function will continue running in background until it ends, the function must not
+// share state with the caller that can be used after the timeout.
+func RunWithTimeout[T any](ctx context.Context, timeout time.Duration, f func(ctx context.Context) (T, error)) (res T, timedOut bool, err error) {
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		res T
+		err error
+	}
+	resC := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				resC <- result{err: fmt.Errorf("panic: %v", p)}
+			}
+		}()
+
+		r, err := f(stepCtx)
+		resC <- result{res: r, err: err}
+	}()
+
+	var zero T
+	select {
+	case r := <-resC:
+		// Results obtained after the context is done are not valid.
+		if stepCtx.Err() == nil {
+			return r.res, false, r.err
+		}
+	case <-stepCtx.Done():
+	}
+
+	// Parent context done, not our timeout.
+	if err := ctx.Err(); err != nil {
+		return zero, false, err
+	}
+	return zero, true, nil
+}
@@ -1,7 +1,10 @@
 package helpers_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
@@ -9,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/internal/helpers"
 )
 
@@ -260,3 +264,139 @@ func TestObjectCreator(t *testing.T) {
 		})
 	}
 }
+
+type customObject struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+}
+
+func TestCheckObjectKind(t *testing.T) {
+	tests := map[string]struct {
+		obj    metav1.Object
+		gvk    *metav1.GroupVersionKind
+		expErr bool
+	}{
+		"A missing kind shouldn't be checked.": {
+			obj: &v1.Pod{},
+		},
+
+		"A registered type with the same kind should not fail.": {
+			obj: &v1.Pod{},
+			gvk: &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		},
+
+		"A registered type with a different kind should fail.": {
+			obj:    &v1.Pod{},
+			gvk:    &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			expErr: true,
+		},
+
+		"A registered type with a different group should fail.": {
+			obj:    &appsv1.Deployment{},
+			gvk:    &metav1.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Deployment"},
+			expErr: true,
+		},
+
+		"A missing admission review kind should use the object kind.": {
+			obj:    &v1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}},
+			expErr: true,
+		},
+
+		"A not registered type should check the kind based on the type name.": {
+			obj: &customObject{},
+			gvk: &metav1.GroupVersionKind{Group: "custom.slok.dev", Version: "v1", Kind: "customObject"},
+		},
+
+		"A not registered type with a different kind should fail.": {
+			obj:    &customObject{},
+			gvk:    &metav1.GroupVersionKind{Group: "custom.slok.dev", Version: "v1", Kind: "otherObject"},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := helpers.CheckObjectKind(test.obj, test.gvk)
+			if test.expErr {
+				var typedErr *webhook.InvalidObjectTypeError
+				assert.ErrorAs(t, err, &typedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewTypedObject(t *testing.T) {
+	pod, err := helpers.NewTypedObject[*v1.Pod]()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &v1.Pod{}, pod)
+	}
+
+	_, err = helpers.NewTypedObject[metav1.Object]()
+	assert.Error(t, err)
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	slow := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "slow", nil
+	}
+
+	tests := map[string]struct {
+		ctx         func() context.Context
+		f           func(ctx context.Context) (string, error)
+		expRes      string
+		expTimedOut bool
+		expErr      bool
+	}{
+		"A function that finishes on time should return its result.": {
+			ctx:    context.Background,
+			f:      func(_ context.Context) (string, error) { return "fast", nil },
+			expRes: "fast",
+		},
+
+		"A function that fails on time should return its error.": {
+			ctx:    context.Background,
+			f:      func(_ context.Context) (string, error) { return "", fmt.Errorf("something") },
+			expErr: true,
+		},
+
+		"A function that panics should return an error.": {
+			ctx:    context.Background,
+			f:      func(_ context.Context) (string, error) { panic("something") },
+			expErr: true,
+		},
+
+		"A function that doesn't finish on time should time out.": {
+			ctx:         context.Background,
+			f:           slow,
+			expTimedOut: true,
+		},
+
+		"A function whose parent context is done should return the context error.": {
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			f:      slow,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotRes, gotTimedOut, err := helpers.RunWithTimeout(test.ctx(), 20*time.Millisecond, test.f)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expRes, gotRes)
+				assert.Equal(test.expTimedOut, gotTimedOut)
+			}
+		})
+	}
+}
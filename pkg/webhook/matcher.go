@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+)
+
+// Matcher knows how to check if an admission review matches a criteria, mutators and validators
+// can be wrapped with matchers to be executed only on the matched requests
+// (e.g: `mutating.NewMatchMutator`, `validating.NewMatchValidator`).
+type Matcher interface {
+	// Match returns true if the admission review and its object (can be nil) match.
+	Match(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (bool, error)
+}
+
+// MatcherFunc is a helper type to create matchers from functions.
+type MatcherFunc func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (bool, error)
+
+// Match satisfies Matcher interface.
+func (f MatcherFunc) Match(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (bool, error) {
+	return f(ctx, ar, obj)
+}
+
+// NamespaceLabelsGetter knows how to get the labels of a namespace.
+type NamespaceLabelsGetter interface {
+	GetNamespaceLabels(ctx context.Context, namespace string) (map[string]string, error)
+}
+
+// NamespaceLabelsGetterFunc is a helper type to create namespace label getters from functions.
+type NamespaceLabelsGetterFunc func(ctx context.Context, namespace string) (map[string]string, error)
+
+// GetNamespaceLabels satisfies NamespaceLabelsGetter interface.
+func (f NamespaceLabelsGetterFunc) GetNamespaceLabels(ctx context.Context, namespace string) (map[string]string, error) {
+	return f(ctx, namespace)
+}
+
+// MatcherConfig is the configuration of the declarative matcher.
+//
+// All the set criteria must match, and on each criteria, any of the values can match. The
+// criteria that are not set will match everything. The patterns use glob syntax (`path.Match`),
+// `*` doesn't match `/`.
+type MatcherConfig struct {
+	// Operations are the matched operations.
+	Operations []model.AdmissionReviewOp
+	// Kinds are the matched kind patterns, the core group is the empty group (e.g: `{Group: "apps", Version: "*", Kind: "Deployment"}`).
+	Kinds []metav1.GroupVersionKind
+	// Resources are the matched resource patterns, the subresources can be matched with the `resource/subresource`
+	// format (e.g: `{Group: "", Version: "v1", Resource: "pods/*"}`). `*` will not match subresources, use `*/*` instead.
+	Resources []metav1.GroupVersionResource
+	// Namespaces are the matched namespace name patterns. Cluster scoped resources have empty namespace.
+	Namespaces []string
+	// NamespaceSelector is the label selector of the matched namespaces, requires NamespaceLabelsGetter.
+	// Cluster scoped resources always match.
+	NamespaceSelector *metav1.LabelSelector
+	// NamespaceLabelsGetter is used to get the labels of the namespaces for the NamespaceSelector (e.g: a
+	// cached Kubernetes client).
+	NamespaceLabelsGetter NamespaceLabelsGetter
+	// ObjectSelector is the label selector of the matched objects.
+	ObjectSelector *metav1.LabelSelector
+	// AnnotationSelector is the label selector applied on the annotations of the matched objects.
+	AnnotationSelector *metav1.LabelSelector
+	// Usernames are the matched request user name patterns.
+	Usernames []string
+	// Groups are the matched request user group patterns.
+	Groups []string
+	// ServiceAccounts are the matched request service account patterns, in `namespace/name` format
+	// (e.g: `kube-system/*`).
+	ServiceAccounts []string
+}
+
+func (c *MatcherConfig) defaults() error {
+	patterns := append(append(append([]string{}, c.Namespaces...), c.Usernames...), c.Groups...)
+	patterns = append(patterns, c.ServiceAccounts...)
+	for _, k := range c.Kinds {
+		patterns = append(patterns, k.Group, k.Version, k.Kind)
+	}
+	for _, r := range c.Resources {
+		patterns = append(patterns, r.Group, r.Version, r.Resource)
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid %q pattern: %w", p, err)
+		}
+	}
+
+	if c.NamespaceSelector != nil && c.NamespaceLabelsGetter == nil {
+		return fmt.Errorf("namespace labels getter is required when using a namespace selector")
+	}
+
+	return nil
+}
+
+type matcher struct {
+	cfg                MatcherConfig
+	namespaceSelector  labels.Selector
+	objectSelector     labels.Selector
+	annotationSelector labels.Selector
+}
+
+// NewMatcher returns a new declarative matcher.
+func NewMatcher(cfg MatcherConfig) (Matcher, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	nsSelector, err := labelSelectorAsSelector(cfg.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+
+	objSelector, err := labelSelectorAsSelector(cfg.ObjectSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object selector: %w", err)
+	}
+
+	annotationSelector, err := labelSelectorAsSelector(cfg.AnnotationSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation selector: %w", err)
+	}
+
+	return matcher{
+		cfg:                cfg,
+		namespaceSelector:  nsSelector,
+		objectSelector:     objSelector,
+		annotationSelector: annotationSelector,
+	}, nil
+}
+
+func (m matcher) Match(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (bool, error) {
+	if !m.matchRequest(ar) {
+		return false, nil
+	}
+
+	if obj != nil {
+		if m.objectSelector != nil && !m.objectSelector.Matches(labels.Set(obj.GetLabels())) {
+			return false, nil
+		}
+
+		if m.annotationSelector != nil && !m.annotationSelector.Matches(labels.Set(obj.GetAnnotations())) {
+			return false, nil
+		}
+	}
+
+	// Last one, this can be expensive.
+	if m.namespaceSelector != nil && ar.Namespace != "" {
+		nsLabels, err := m.cfg.NamespaceLabelsGetter.GetNamespaceLabels(ctx, ar.Namespace)
+		if err != nil {
+			return false, fmt.Errorf("could not get %q namespace labels: %w", ar.Namespace, err)
+		}
+
+		if !m.namespaceSelector.Matches(labels.Set(nsLabels)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// matchRequest matches the admission review request data.
+func (m matcher) matchRequest(ar *model.AdmissionReview) bool {
+	if len(m.cfg.Operations) > 0 && !matchAny(m.cfg.Operations, func(op model.AdmissionReviewOp) bool { return op == ar.Operation }) {
+		return false
+	}
+
+	if len(m.cfg.Kinds) > 0 {
+		if ar.GVK == nil {
+			return false
+		}
+		gvk := *ar.GVK
+		matched := matchAny(m.cfg.Kinds, func(p metav1.GroupVersionKind) bool {
+			return globMatch(p.Group, gvk.Group) && globMatch(p.Version, gvk.Version) && globMatch(p.Kind, gvk.Kind)
+		})
+		if !matched {
+			return false
+		}
+	}
+
+	if len(m.cfg.Resources) > 0 {
+		if ar.GVR == nil {
+			return false
+		}
+		gvr := *ar.GVR
+		resource := gvr.Resource
+		if ar.SubResource != "" {
+			resource = resource + "/" + ar.SubResource
+		}
+		matched := matchAny(m.cfg.Resources, func(p metav1.GroupVersionResource) bool {
+			return globMatch(p.Group, gvr.Group) && globMatch(p.Version, gvr.Version) && globMatch(p.Resource, resource)
+		})
+		if !matched {
+			return false
+		}
+	}
+
+	if len(m.cfg.Namespaces) > 0 && !matchAny(m.cfg.Namespaces, func(p string) bool { return globMatch(p, ar.Namespace) }) {
+		return false
+	}
+
+	if len(m.cfg.Usernames) > 0 && !matchAny(m.cfg.Usernames, func(p string) bool { return globMatch(p, ar.UserInfo.Username) }) {
+		return false
+	}
+
+	if len(m.cfg.Groups) > 0 {
+		matched := matchAny(m.cfg.Groups, func(p string) bool {
+			return matchAny(ar.UserInfo.Groups, func(g string) bool { return globMatch(p, g) })
+		})
+		if !matched {
+			return false
+		}
+	}
+
+	if len(m.cfg.ServiceAccounts) > 0 {
+		// Service account user names are in `system:serviceaccount:{namespace}:{name}` format.
+		sa, ok := strings.CutPrefix(ar.UserInfo.Username, "system:serviceaccount:")
+		if !ok {
+			return false
+		}
+		sa = strings.Replace(sa, ":", "/", 1)
+		if !matchAny(m.cfg.ServiceAccounts, func(p string) bool { return globMatch(p, sa) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchAny[T any](values []T, match func(T) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a value against a pattern, the patterns are validated on the configuration.
+func globMatch(pattern, value string) bool {
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func labelSelectorAsSelector(ls *metav1.LabelSelector) (labels.Selector, error) {
+	if ls == nil {
+		return nil, nil
+	}
+
+	return metav1.LabelSelectorAsSelector(ls)
+}
@@ -0,0 +1,220 @@
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+func TestMatcher(t *testing.T) {
+	getReview := func() *model.AdmissionReview {
+		return &model.AdmissionReview{
+			Namespace:   "test-ns",
+			Operation:   model.OperationCreate,
+			GVK:         &metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+			GVR:         &metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"},
+			SubResource: "",
+			UserInfo: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:kube-system:replicaset-controller",
+				Groups:   []string{"system:serviceaccounts", "system:authenticated"},
+			},
+		}
+	}
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "test-ns",
+			Labels:      map[string]string{"app": "test"},
+			Annotations: map[string]string{"example.com/inject": "true"},
+		}}
+	}
+	nsLabels := webhook.NamespaceLabelsGetterFunc(func(_ context.Context, ns string) (map[string]string, error) {
+		if ns != "test-ns" {
+			return nil, fmt.Errorf("unknown namespace")
+		}
+		return map[string]string{"team": "a"}, nil
+	})
+
+	tests := map[string]struct {
+		cfg       webhook.MatcherConfig
+		review    func() *model.AdmissionReview
+		expMatch  bool
+		expErr    bool
+		expCfgErr bool
+	}{
+		"Without criteria, everything should match.": {
+			cfg:      webhook.MatcherConfig{},
+			expMatch: true,
+		},
+
+		"Matching all the criteria should match.": {
+			cfg: webhook.MatcherConfig{
+				Operations:            []model.AdmissionReviewOp{model.OperationCreate, model.OperationUpdate},
+				Kinds:                 []metav1.GroupVersionKind{{Group: "apps", Version: "*", Kind: "Deployment"}, {Group: "", Version: "*", Kind: "Pod"}},
+				Resources:             []metav1.GroupVersionResource{{Group: "", Version: "v1", Resource: "pods"}},
+				Namespaces:            []string{"test-*"},
+				NamespaceSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				NamespaceLabelsGetter: nsLabels,
+				ObjectSelector:        &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+				AnnotationSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"example.com/inject": "true"}},
+				Usernames:             []string{"system:serviceaccount:*"},
+				Groups:                []string{"system:serviceaccounts"},
+				ServiceAccounts:       []string{"kube-system/*"},
+			},
+			expMatch: true,
+		},
+
+		"Not matching the operation should not match.": {
+			cfg:      webhook.MatcherConfig{Operations: []model.AdmissionReviewOp{model.OperationDelete}},
+			expMatch: false,
+		},
+
+		"Not matching the kind should not match.": {
+			cfg:      webhook.MatcherConfig{Kinds: []metav1.GroupVersionKind{{Group: "apps", Version: "*", Kind: "*"}}},
+			expMatch: false,
+		},
+
+		"Not matching the resource should not match.": {
+			cfg:      webhook.MatcherConfig{Resources: []metav1.GroupVersionResource{{Group: "", Version: "v1", Resource: "services"}}},
+			expMatch: false,
+		},
+
+		"Not matching the subresource should not match.": {
+			cfg: webhook.MatcherConfig{Resources: []metav1.GroupVersionResource{{Group: "*", Version: "*", Resource: "*"}}},
+			review: func() *model.AdmissionReview {
+				r := getReview()
+				r.SubResource = "status"
+				return r
+			},
+			expMatch: false,
+		},
+
+		"Matching the subresource should match.": {
+			cfg: webhook.MatcherConfig{Resources: []metav1.GroupVersionResource{{Group: "*", Version: "*", Resource: "pods/*"}}},
+			review: func() *model.AdmissionReview {
+				r := getReview()
+				r.SubResource = "status"
+				return r
+			},
+			expMatch: true,
+		},
+
+		"Not matching the namespace should not match.": {
+			cfg:      webhook.MatcherConfig{Namespaces: []string{"default", "kube-*"}},
+			expMatch: false,
+		},
+
+		"Not matching the namespace selector should not match.": {
+			cfg: webhook.MatcherConfig{
+				NamespaceSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+				NamespaceLabelsGetter: nsLabels,
+			},
+			expMatch: false,
+		},
+
+		"Failing getting the namespace labels should fail.": {
+			cfg: webhook.MatcherConfig{
+				NamespaceSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				NamespaceLabelsGetter: nsLabels,
+			},
+			review: func() *model.AdmissionReview {
+				r := getReview()
+				r.Namespace = "other-ns"
+				return r
+			},
+			expErr: true,
+		},
+
+		"Not matching the object selector should not match.": {
+			cfg: webhook.MatcherConfig{ObjectSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"test"}}},
+			}},
+			expMatch: false,
+		},
+
+		"Not matching the annotation selector should not match.": {
+			cfg:      webhook.MatcherConfig{AnnotationSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"example.com/inject": "false"}}},
+			expMatch: false,
+		},
+
+		"Not matching the user should not match.": {
+			cfg:      webhook.MatcherConfig{Usernames: []string{"admin"}},
+			expMatch: false,
+		},
+
+		"Not matching the groups should not match.": {
+			cfg:      webhook.MatcherConfig{Groups: []string{"system:masters"}},
+			expMatch: false,
+		},
+
+		"Not matching the service account should not match.": {
+			cfg:      webhook.MatcherConfig{ServiceAccounts: []string{"kube-system/deployment-controller"}},
+			expMatch: false,
+		},
+
+		"Not being a service account should not match.": {
+			cfg: webhook.MatcherConfig{ServiceAccounts: []string{"*/*"}},
+			review: func() *model.AdmissionReview {
+				r := getReview()
+				r.UserInfo.Username = "admin"
+				return r
+			},
+			expMatch: false,
+		},
+
+		"Requests without kind should not match kinds.": {
+			cfg: webhook.MatcherConfig{Kinds: []metav1.GroupVersionKind{{Group: "*", Version: "*", Kind: "*"}}},
+			review: func() *model.AdmissionReview {
+				r := getReview()
+				r.GVK = nil
+				return r
+			},
+			expMatch: false,
+		},
+
+		"An invalid pattern should fail the configuration.": {
+			cfg:       webhook.MatcherConfig{Namespaces: []string{"["}},
+			expCfgErr: true,
+		},
+
+		"A namespace selector without namespace labels getter should fail the configuration.": {
+			cfg:       webhook.MatcherConfig{NamespaceSelector: &metav1.LabelSelector{}},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			matcher, err := webhook.NewMatcher(test.cfg)
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			review := getReview
+			if test.review != nil {
+				review = test.review
+			}
+			gotMatch, err := matcher.Match(context.TODO(), review(), getPod())
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expMatch, gotMatch)
+			}
+		})
+	}
+}
@@ -35,23 +35,211 @@ type MeasureMutatingOpData struct {
 	Mutated bool
 }
 
+// MeasureFailurePolicyOpData is the data to measure a failure policy applied by the
+// webhook handler when a webhook review could not be handled correctly.
+type MeasureFailurePolicyOpData struct {
+	WebhookID              string
+	WebhookType            string
+	AdmissionReviewVersion string
+	FailurePolicy          string
+	Reason                 string
+}
+
+// MeasureConcurrencyOpData is the data to measure the concurrency state of a webhook handler.
+type MeasureConcurrencyOpData struct {
+	WebhookID   string
+	WebhookType string
+	Inflight    int
+	Queued      int
+}
+
+// MeasureMutationIdempotencyOpData is the data to measure a mutation idempotency check
+// of a mutating webhook.
+type MeasureMutationIdempotencyOpData struct {
+	WebhookID              string
+	AdmissionReviewVersion string
+	ResourceKind           string
+	Operation              string
+	Idempotent             bool
+}
+
+// MeasureValidatorEnforcementOpData is the data to measure the decision of an enforcement validator.
+type MeasureValidatorEnforcementOpData struct {
+	ValidatorName   string
+	EnforcementMode string
+	Decision        string
+}
+
+// MeasureCanaryOpData is the data to measure a review of a canary rollout.
+type MeasureCanaryOpData struct {
+	CanaryName  string
+	WebhookType string
+	Variant     string
+	Success     bool
+	Allowed     bool
+}
+
+// MeasureStepTimeoutOpData is the data to measure a timed out step (mutator or validator) of a webhook.
+type MeasureStepTimeoutOpData struct {
+	StepName      string
+	WebhookType   string
+	TimeoutPolicy string
+}
+
+// MeasureDependencyCircuitBreakerOpData is the data to measure a state change of a dependency circuit breaker.
+type MeasureDependencyCircuitBreakerOpData struct {
+	Dependency string
+	State      string
+}
+
 // MetricsRecorder knows how to record webhook recorder metrics.
+//
+// The recorders can implement the optional recorder interfaces (e.g `FailurePolicyMetricsRecorder`,
+// `CanaryMetricsRecorder`...) to measure the features that use them, the features will detect them at
+// runtime and ignore the measurements if they are not implemented (see `NewFullMetricsRecorder`).
 type MetricsRecorder interface {
 	MeasureValidatingWebhookReviewOp(ctx context.Context, data MeasureValidatingOpData)
 	MeasureMutatingWebhookReviewOp(ctx context.Context, data MeasureMutatingOpData)
 }
 
+// FailurePolicyMetricsRecorder is an optional metrics recorder that knows how to measure the
+// failure policies applied by the webhook handler.
+type FailurePolicyMetricsRecorder interface {
+	MeasureFailurePolicyOp(ctx context.Context, data MeasureFailurePolicyOpData)
+}
+
+// ConcurrencyMetricsRecorder is an optional metrics recorder that knows how to measure the
+// concurrency state of the webhook handlers.
+type ConcurrencyMetricsRecorder interface {
+	MeasureConcurrencyOp(ctx context.Context, data MeasureConcurrencyOpData)
+}
+
+// MutationIdempotencyMetricsRecorder is an optional metrics recorder that knows how to measure the
+// mutation idempotency checks of the mutating webhooks.
+type MutationIdempotencyMetricsRecorder interface {
+	MeasureMutationIdempotencyOp(ctx context.Context, data MeasureMutationIdempotencyOpData)
+}
+
+// ValidatorEnforcementMetricsRecorder is an optional metrics recorder that knows how to measure the
+// decisions of the enforcement validators.
+type ValidatorEnforcementMetricsRecorder interface {
+	MeasureValidatorEnforcementOp(ctx context.Context, data MeasureValidatorEnforcementOpData)
+}
+
+// CanaryMetricsRecorder is an optional metrics recorder that knows how to measure the reviews
+// of the canary rollouts.
+type CanaryMetricsRecorder interface {
+	MeasureCanaryOp(ctx context.Context, data MeasureCanaryOpData)
+}
+
+// StepTimeoutMetricsRecorder is an optional metrics recorder that knows how to measure the
+// timed out webhook steps.
+type StepTimeoutMetricsRecorder interface {
+	MeasureStepTimeoutOp(ctx context.Context, data MeasureStepTimeoutOpData)
+}
+
+// DependencyCircuitBreakerMetricsRecorder is an optional metrics recorder that knows how to measure
+// the state changes of the dependencies circuit breakers.
+type DependencyCircuitBreakerMetricsRecorder interface {
+	MeasureDependencyCircuitBreakerOp(ctx context.Context, data MeasureDependencyCircuitBreakerOpData)
+}
+
+// FullMetricsRecorder is a metrics recorder that implements all the optional metrics recorders.
+type FullMetricsRecorder interface {
+	MetricsRecorder
+	FailurePolicyMetricsRecorder
+	ConcurrencyMetricsRecorder
+	MutationIdempotencyMetricsRecorder
+	ValidatorEnforcementMetricsRecorder
+	CanaryMetricsRecorder
+	StepTimeoutMetricsRecorder
+	DependencyCircuitBreakerMetricsRecorder
+}
+
+//go:generate mockery --case underscore --output webhookmock --outpkg webhookmock --name FullMetricsRecorder
+
+// NewFullMetricsRecorder returns a full metrics recorder based on the received recorder, the optional
+// measurements that are not implemented by the recorder will be ignored.
+func NewFullMetricsRecorder(rec MetricsRecorder) FullMetricsRecorder {
+	if rec == nil {
+		return NoopMetricsRecorder
+	}
+
+	if frec, ok := rec.(FullMetricsRecorder); ok {
+		return frec
+	}
+
+	f := fullMetricsRecorder{
+		MetricsRecorder:                         rec,
+		FailurePolicyMetricsRecorder:            NoopMetricsRecorder,
+		ConcurrencyMetricsRecorder:              NoopMetricsRecorder,
+		MutationIdempotencyMetricsRecorder:      NoopMetricsRecorder,
+		ValidatorEnforcementMetricsRecorder:     NoopMetricsRecorder,
+		CanaryMetricsRecorder:                   NoopMetricsRecorder,
+		StepTimeoutMetricsRecorder:              NoopMetricsRecorder,
+		DependencyCircuitBreakerMetricsRecorder: NoopMetricsRecorder,
+	}
+	if r, ok := rec.(FailurePolicyMetricsRecorder); ok {
+		f.FailurePolicyMetricsRecorder = r
+	}
+	if r, ok := rec.(ConcurrencyMetricsRecorder); ok {
+		f.ConcurrencyMetricsRecorder = r
+	}
+	if r, ok := rec.(MutationIdempotencyMetricsRecorder); ok {
+		f.MutationIdempotencyMetricsRecorder = r
+	}
+	if r, ok := rec.(ValidatorEnforcementMetricsRecorder); ok {
+		f.ValidatorEnforcementMetricsRecorder = r
+	}
+	if r, ok := rec.(CanaryMetricsRecorder); ok {
+		f.CanaryMetricsRecorder = r
+	}
+	if r, ok := rec.(StepTimeoutMetricsRecorder); ok {
+		f.StepTimeoutMetricsRecorder = r
+	}
+	if r, ok := rec.(DependencyCircuitBreakerMetricsRecorder); ok {
+		f.DependencyCircuitBreakerMetricsRecorder = r
+	}
+
+	return f
+}
+
+type fullMetricsRecorder struct {
+	MetricsRecorder
+	FailurePolicyMetricsRecorder
+	ConcurrencyMetricsRecorder
+	MutationIdempotencyMetricsRecorder
+	ValidatorEnforcementMetricsRecorder
+	CanaryMetricsRecorder
+	StepTimeoutMetricsRecorder
+	DependencyCircuitBreakerMetricsRecorder
+}
+
 type noopMetricsRecorder int
 
 // NoopMetricsRecorder is a no-op metrics recorder.
 const NoopMetricsRecorder = noopMetricsRecorder(0)
 
-var _ MetricsRecorder = NoopMetricsRecorder
+var _ FullMetricsRecorder = NoopMetricsRecorder
 
 func (noopMetricsRecorder) MeasureValidatingWebhookReviewOp(ctx context.Context, data MeasureValidatingOpData) {
 }
 func (noopMetricsRecorder) MeasureMutatingWebhookReviewOp(ctx context.Context, data MeasureMutatingOpData) {
 }
+func (noopMetricsRecorder) MeasureFailurePolicyOp(ctx context.Context, data MeasureFailurePolicyOpData) {
+}
+func (noopMetricsRecorder) MeasureConcurrencyOp(ctx context.Context, data MeasureConcurrencyOpData) {
+}
+func (noopMetricsRecorder) MeasureMutationIdempotencyOp(ctx context.Context, data MeasureMutationIdempotencyOpData) {
+}
+func (noopMetricsRecorder) MeasureValidatorEnforcementOp(ctx context.Context, data MeasureValidatorEnforcementOpData) {
+}
+func (noopMetricsRecorder) MeasureCanaryOp(ctx context.Context, data MeasureCanaryOpData) {
+}
+func (noopMetricsRecorder) MeasureStepTimeoutOp(ctx context.Context, data MeasureStepTimeoutOpData) {
+}
+func (noopMetricsRecorder) MeasureDependencyCircuitBreakerOp(ctx context.Context, data MeasureDependencyCircuitBreakerOpData) {
+}
 
 type measuredWebhook struct {
 	webhookID   string
@@ -74,11 +262,14 @@ func (m measuredWebhook) ID() string              { return m.next.ID() }
 func (m measuredWebhook) Kind() model.WebhookKind { return m.next.Kind() }
 func (m measuredWebhook) Review(ctx context.Context, ar model.AdmissionReview) (resp model.AdmissionResponse, err error) {
 	defer func(t0 time.Time) {
+		// Panics are measured as failures and propagated.
+		panicked := recover()
+
 		cData := MeasureOpCommonData{
 			WebhookID:              m.webhookID,
 			AdmissionReviewVersion: string(ar.Version),
 			Duration:               time.Since(t0),
-			Success:                err == nil,
+			Success:                err == nil && panicked == nil,
 			ResourceName:           ar.Name,
 			ResourceNamespace:      ar.Namespace,
 			Operation:              string(ar.Operation),
@@ -103,11 +294,27 @@ func (m measuredWebhook) Review(ctx context.Context, ar model.AdmissionReview) (
 				Mutated:             hasMutated(r),
 			})
 
+		case nil:
+			// Failed reviews (errors, recovered panics...) don't have response,
+			// measure them as failures based on the webhook kind.
+			cData.Success = false
+			switch m.webhookKind {
+			case model.WebhookKindValidating:
+				cData.WebhookType = model.WebhookKindValidating
+				m.rec.MeasureValidatingWebhookReviewOp(ctx, MeasureValidatingOpData{MeasureOpCommonData: cData})
+			case model.WebhookKindMutating:
+				cData.WebhookType = model.WebhookKindMutating
+				m.rec.MeasureMutatingWebhookReviewOp(ctx, MeasureMutatingOpData{MeasureOpCommonData: cData})
+			}
+
 		default:
 			// Unknown type, not measuring.
 			// TODO(slok): Notify user ignore metrics.
 		}
 
+		if panicked != nil {
+			panic(panicked)
+		}
 	}(time.Now())
 
 	return m.next.Review(ctx, ar)
@@ -119,5 +326,5 @@ func getResourceKind(ar model.AdmissionReview) string {
 }
 
 func hasMutated(r *model.MutatingAdmissionResponse) bool {
-	return len(r.JSONPatchPatch) > 0 && string(r.JSONPatchPatch) != "[]"
+	return !r.Denied && len(r.JSONPatchPatch) > 0 && string(r.JSONPatchPatch) != "[]"
 }
@@ -0,0 +1,105 @@
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestNewFullMetricsRecorder(t *testing.T) {
+	tests := map[string]struct {
+		recorder func(t *testing.T) webhook.MetricsRecorder
+	}{
+		"A recorder without the optional measurements should measure the base ones and ignore the optional ones.": {
+			recorder: func(t *testing.T) webhook.MetricsRecorder {
+				m := webhookmock.NewMetricsRecorder(t)
+				m.On("MeasureValidatingWebhookReviewOp", mock.Anything, mock.Anything).Once().Return()
+				return m
+			},
+		},
+
+		"A recorder with the optional measurements should measure all.": {
+			recorder: func(t *testing.T) webhook.MetricsRecorder {
+				m := webhookmock.NewFullMetricsRecorder(t)
+				m.On("MeasureValidatingWebhookReviewOp", mock.Anything, mock.Anything).Once().Return()
+				m.On("MeasureCanaryOp", mock.Anything, mock.Anything).Once().Return()
+				return m
+			},
+		},
+
+		"A missing recorder should not measure.": {
+			recorder: func(t *testing.T) webhook.MetricsRecorder { return nil },
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rec := webhook.NewFullMetricsRecorder(test.recorder(t))
+
+			assert.NotPanics(func() {
+				rec.MeasureValidatingWebhookReviewOp(context.TODO(), webhook.MeasureValidatingOpData{})
+				rec.MeasureCanaryOp(context.TODO(), webhook.MeasureCanaryOpData{})
+			})
+		})
+	}
+}
+
+func TestMeasuredWebhookFailures(t *testing.T) {
+	tests := map[string]struct {
+		err   error
+		panic bool
+	}{
+		"A failed review should be measured as a failure.": {
+			err: fmt.Errorf("wanted"),
+		},
+
+		"A review without response should be measured as a failure.": {},
+
+		"A panicking review should be measured as a failure and keep panicking.": {
+			panic: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			mwh := &webhookmock.Webhook{}
+			mwh.On("ID").Return("test")
+			mwh.On("Kind").Return(model.WebhookKind(model.WebhookKindValidating))
+			mwh.On("Review", mock.Anything, mock.Anything).Once().Return(func(context.Context, model.AdmissionReview) model.AdmissionResponse {
+				if test.panic {
+					panic("wanted")
+				}
+				return nil
+			}, test.err)
+
+			mrec := webhookmock.NewMetricsRecorder(t)
+			mrec.On("MeasureValidatingWebhookReviewOp", mock.Anything, mock.Anything).Once().Run(func(args mock.Arguments) {
+				data := args.Get(1).(webhook.MeasureValidatingOpData)
+				assert.False(data.Success)
+				assert.False(data.Allowed)
+			}).Return()
+
+			wh := webhook.NewMeasuredWebhook(mrec, mwh)
+			review := func() {
+				_, _ = wh.Review(context.TODO(), model.AdmissionReview{RequestGVK: &metav1.GroupVersionKind{Kind: "Pod"}})
+			}
+			if test.panic {
+				assert.Panics(review)
+			} else {
+				assert.NotPanics(review)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package mutating
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+)
+
+// PatchConflictPolicy is the policy applied when a mutator of a chain changes a path
+// already changed by a previous mutator.
+type PatchConflictPolicy string
+
+const (
+	// PatchConflictPolicyIgnore will ignore the conflicts.
+	PatchConflictPolicyIgnore PatchConflictPolicy = "ignore"
+	// PatchConflictPolicyWarn will log the conflicts and return them as warnings.
+	PatchConflictPolicyWarn PatchConflictPolicy = "warn"
+	// PatchConflictPolicyFail will fail the mutation with an error.
+	PatchConflictPolicyFail PatchConflictPolicy = "fail"
+)
+
+// NamedMutator is a mutator with a name, the name is used by the chains to identify
+// the mutator (e.g: patch attribution).
+type NamedMutator interface {
+	Mutator
+	Name() string
+}
+
+// NewNamedMutator returns a new mutator with a name.
+func NewNamedMutator(name string, m Mutator) NamedMutator {
+	return namedMutator{Mutator: m, name: name}
+}
+
+type namedMutator struct {
+	Mutator
+	name string
+}
+
+func (n namedMutator) Name() string { return n.name }
+
+// mutatorName returns the name of the mutator, if the mutator doesn't have name
+// the position on the chain will be used.
+func mutatorName(i int, m Mutator) string {
+	if nm, ok := m.(NamedMutator); ok && nm.Name() != "" {
+		return nm.Name()
+	}
+
+	return fmt.Sprintf("mutator-%d", i)
+}
+
+// patchAttributor knows how to compute the JSON patch applied by each mutator of a chain
+// and detect the conflicts between them.
+type patchAttributor struct {
+	mutatorPatches []model.MutatorPatch
+	changes        []pathChange
+	detectConflict bool
+}
+
+type pathChange struct {
+	path    string
+	mutator string
+}
+
+func newPatchAttributor(policy PatchConflictPolicy) *patchAttributor {
+	return &patchAttributor{
+		detectConflict: policy != PatchConflictPolicyIgnore,
+	}
+}
+
+// add computes the patch of a mutator and returns the conflicting paths with previous mutators.
+func (p *patchAttributor) add(name string, before []byte, obj interface{}) (*model.MutatorPatch, []string, error) {
+	after, err := json.Marshal(obj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal into JSON the object after the mutator %q: %w", name, err)
+	}
+
+	ops, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create JSON patch of the mutator %q: %w", name, err)
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal into JSON the JSON patch of the mutator %q: %w", name, err)
+	}
+
+	var conflicts []string
+	if p.detectConflict {
+		for _, op := range ops {
+			// Only the operations that overwrite previous changes conflict (e.g: adding a label to
+			// the labels created by a previous mutator doesn't conflict).
+			if op.Operation != "replace" && op.Operation != "remove" {
+				continue
+			}
+
+			for _, c := range p.changes {
+				if pathsOverlap(c.path, op.Path) {
+					conflicts = append(conflicts, fmt.Sprintf("%s (%s)", op.Path, c.mutator))
+					break
+				}
+			}
+		}
+	}
+	for _, op := range ops {
+		p.changes = append(p.changes, pathChange{path: op.Path, mutator: name})
+	}
+
+	mp := model.MutatorPatch{Mutator: name, JSONPatchPatch: patch}
+	p.mutatorPatches = append(p.mutatorPatches, mp)
+
+	return &mp, conflicts, nil
+}
+
+func (p *patchAttributor) patches() []model.MutatorPatch {
+	if p == nil {
+		return nil
+	}
+
+	return p.mutatorPatches
+}
+
+// pathsOverlap returns true if the JSON pointer paths are the same, or one of them
+// is the parent of the other one (e.g: replacing a value inside a map created
+// previously, or removing a map that has a value added previously).
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
@@ -0,0 +1,96 @@
+package mutating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// CanaryMutatorConfig is the configuration of a canary mutator.
+type CanaryMutatorConfig struct {
+	// Name is the name of the canary rollout, used on the logs and metrics.
+	Name string
+	// Stable is the mutator used by the not selected requests (e.g: the old implementation).
+	Stable Mutator
+	// Canary is the mutator used by the selected requests (e.g: the new implementation).
+	Canary Mutator
+	// Selector selects the requests that will use the canary mutator.
+	Selector webhook.CanarySelector
+	// Logger is the logger.
+	Logger log.Logger
+	// MetricsRecorder is used to measure the reviews of both variants. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+func (c *CanaryMutatorConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.Stable == nil {
+		return fmt.Errorf("stable mutator is required")
+	}
+
+	if c.Canary == nil {
+		return fmt.Errorf("canary mutator is required")
+	}
+
+	if err := c.Selector.Validate(); err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"canary": c.Name})
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+type canaryMutator struct {
+	cfg        CanaryMutatorConfig
+	metricsRec webhook.CanaryMetricsRecorder
+}
+
+// NewCanaryMutator returns a mutator that will mutate the requests selected by the canary selector using
+// the canary mutator, and the rest using the stable mutator.
+func NewCanaryMutator(cfg CanaryMutatorConfig) (Mutator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return canaryMutator{
+		cfg:        cfg,
+		metricsRec: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+	}, nil
+}
+
+func (c canaryMutator) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (res *MutatorResult, err error) {
+	variant, m := webhook.CanaryVariantStable, c.cfg.Stable
+	if c.cfg.Selector.Select(ar, obj) {
+		variant, m = webhook.CanaryVariantCanary, c.cfg.Canary
+	}
+
+	defer func() {
+		c.metricsRec.MeasureCanaryOp(ctx, webhook.MeasureCanaryOpData{
+			CanaryName:  c.cfg.Name,
+			WebhookType: model.WebhookKindMutating,
+			Variant:     variant,
+			Success:     err == nil && res != nil,
+			Allowed:     err == nil && res != nil && !res.Denied,
+		})
+	}()
+
+	c.cfg.Logger.WithCtxValues(ctx).WithValues(log.Kv{"variant": variant}).Debugf("Mutating with %q canary variant", variant)
+	return m.Mutate(ctx, ar, obj)
+}
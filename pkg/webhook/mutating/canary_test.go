@@ -0,0 +1,124 @@
+package mutating_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestCanaryMutator(t *testing.T) {
+	stable := getWarnMutator("stable")
+	canary := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+		return &mutating.MutatorResult{Warnings: []string{"canary"}, Denied: true}, nil
+	})
+
+	tests := map[string]struct {
+		selector    webhook.CanarySelector
+		review      model.AdmissionReview
+		expWarnings []string
+		expMetric   webhook.MeasureCanaryOpData
+		expCfgErr   bool
+	}{
+		"Without percent, the requests should use the stable mutator.": {
+			selector:    webhook.CanarySelector{Percent: 0},
+			review:      model.AdmissionReview{Namespace: "test-ns", Name: "test"},
+			expWarnings: []string{"stable"},
+			expMetric:   webhook.MeasureCanaryOpData{CanaryName: "test", WebhookType: "mutating", Variant: "stable", Success: true, Allowed: true},
+		},
+
+		"With all the percent, the requests should use the canary mutator.": {
+			selector:    webhook.CanarySelector{Percent: 100},
+			review:      model.AdmissionReview{Namespace: "test-ns", Name: "test"},
+			expWarnings: []string{"canary"},
+			expMetric:   webhook.MeasureCanaryOpData{CanaryName: "test", WebhookType: "mutating", Variant: "canary", Success: true, Allowed: false},
+		},
+
+		"The requests on the canary namespaces should use the canary mutator.": {
+			selector:    webhook.CanarySelector{Percent: 0, Namespaces: []string{"canary-ns", "test-ns"}},
+			review:      model.AdmissionReview{Namespace: "test-ns", Name: "test"},
+			expWarnings: []string{"canary"},
+			expMetric:   webhook.MeasureCanaryOpData{CanaryName: "test", WebhookType: "mutating", Variant: "canary", Success: true, Allowed: false},
+		},
+
+		"An invalid percent should fail the configuration.": {
+			selector:  webhook.CanarySelector{Percent: 101},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			if !test.expCfgErr {
+				mrec.On("MeasureCanaryOp", mock.Anything, test.expMetric).Once().Return()
+			}
+
+			m, err := mutating.NewCanaryMutator(mutating.CanaryMutatorConfig{
+				Name:            "test",
+				Stable:          stable,
+				Canary:          canary,
+				Selector:        test.selector,
+				MetricsRecorder: mrec,
+			})
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			gotRes, err := m.Mutate(context.TODO(), &test.review, &corev1.Pod{})
+			if assert.NoError(err) {
+				assert.Equal(test.expWarnings, gotRes.Warnings)
+			}
+		})
+	}
+}
+
+func TestCanaryMutatorDeterministicSelection(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	m, err := mutating.NewCanaryMutator(mutating.CanaryMutatorConfig{
+		Name:     "test",
+		Stable:   getWarnMutator("stable"),
+		Canary:   getWarnMutator("canary"),
+		Selector: webhook.CanarySelector{Percent: 30},
+	})
+	require.NoError(err)
+
+	variant := func(ns, name string) string {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+		res, err := m.Mutate(context.TODO(), &model.AdmissionReview{Namespace: ns}, pod)
+		require.NoError(err)
+		return res.Warnings[0]
+	}
+
+	canaries := 0
+	total := 1000
+	for i := 0; i < total; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		v := variant("test-ns", name)
+		if v == "canary" {
+			canaries++
+		}
+
+		// The same object should always use the same variant.
+		assert.Equal(v, variant("test-ns", name))
+	}
+
+	assert.InDelta(300, canaries, 50)
+}
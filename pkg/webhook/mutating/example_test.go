@@ -68,3 +68,22 @@ func ExampleMutator_chainMutatingWebhook() {
 		Mutator: mutating.NewChain(log.Noop, fakeMut, fakeMut2, fakeMut3),
 	})
 }
+
+// typedPodAnnotateMutatingWebhook shows how you would create a typed pod mutating webhook that
+// adds annotations to every pod received, without type assertions.
+func ExampleNewTypedWebhook_typedPodAnnotateMutatingWebhook() {
+	pam := mutating.TypedMutatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*mutating.MutatorResult, error) {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations["mutated"] = "true"
+
+		return &mutating.MutatorResult{}, nil
+	})
+
+	// Create webhook.
+	_, _ = mutating.NewTypedWebhook(mutating.TypedWebhookConfig[*corev1.Pod]{
+		ID:      "typedPodAnnotateMutatingWebhook",
+		Mutator: pam,
+	})
+}
@@ -0,0 +1,109 @@
+package mutating
+
+import (
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+)
+
+// DefaultPatchPathsDeny are the JSON patch paths that by default the mutating webhooks will not allow
+// to be changed, the apiserver rejects or ignores these changes. The paths of the requested subresource
+// are allowed on subresource requests (e.g: `/status` on `status` subresource requests).
+var DefaultPatchPathsDeny = []string{
+	"/metadata/name",
+	"/metadata/namespace",
+	"/metadata/uid",
+	"/status",
+}
+
+// PatchPathViolationPolicy is the policy applied when the JSON patch of a mutation changes
+// a path that is not allowed.
+type PatchPathViolationPolicy string
+
+const (
+	// PatchPathViolationPolicyFail will fail the review.
+	PatchPathViolationPolicyFail PatchPathViolationPolicy = "fail"
+	// PatchPathViolationPolicyStrip will remove the operations of the not allowed paths
+	// from the JSON patch and return a warning.
+	PatchPathViolationPolicyStrip PatchPathViolationPolicy = "strip"
+)
+
+// patchPathGuard knows how to check the JSON patch operations paths against allow and deny lists.
+type patchPathGuard struct {
+	allow  []string
+	deny   []string
+	policy PatchPathViolationPolicy
+}
+
+func newPatchPathGuard(allow, deny []string, policy PatchPathViolationPolicy) (*patchPathGuard, error) {
+	for _, p := range append(append([]string{}, allow...), deny...) {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("invalid patch path %q, must be a JSON pointer (e.g: '/metadata/name')", p)
+		}
+	}
+
+	switch policy {
+	case PatchPathViolationPolicyFail, PatchPathViolationPolicyStrip:
+	default:
+		return nil, fmt.Errorf("unknown patch path violation policy %q", policy)
+	}
+
+	return &patchPathGuard{
+		allow:  allow,
+		deny:   deny,
+		policy: policy,
+	}, nil
+}
+
+// guard checks the JSON patch operations of the admission review mutation and returns the allowed
+// operations and the warnings of the removed ones, if the policy is to fail, it will return an error instead.
+func (g *patchPathGuard) guard(ar model.AdmissionReview, ops []jsonpatch.Operation) ([]jsonpatch.Operation, []string, error) {
+	allowedOps := make([]jsonpatch.Operation, 0, len(ops))
+	var warnings []string
+	for _, op := range ops {
+		if g.allowed(ar.SubResource, op.Path) {
+			allowedOps = append(allowedOps, op)
+			continue
+		}
+
+		if g.policy == PatchPathViolationPolicyFail {
+			return nil, nil, fmt.Errorf("JSON patch %q operation on %q path is not allowed", op.Operation, op.Path)
+		}
+		warnings = append(warnings, fmt.Sprintf("mutation %q operation on %q path is not allowed, ignored", op.Operation, op.Path))
+	}
+
+	return allowedOps, warnings, nil
+}
+
+// allowed returns if a path can be changed. The operations on the parents of the denied paths are not
+// allowed (e.g: `/metadata` changes `/metadata/name`), and the operations on the children of the allowed
+// paths are allowed (e.g: `/metadata/labels` allows `/metadata/labels/app`). The denied paths of the
+// requested subresource are ignored, as it's the part of the object being changed (e.g: `/status` on
+// `status` subresource requests).
+func (g *patchPathGuard) allowed(subResource, path string) bool {
+	subResourcePath := "/" + subResource
+	for _, d := range g.deny {
+		if subResource != "" && (d == subResourcePath || strings.HasPrefix(d, subResourcePath+"/")) {
+			continue
+		}
+
+		if pathsOverlap(d, path) {
+			return false
+		}
+	}
+
+	if len(g.allow) == 0 {
+		return true
+	}
+
+	for _, a := range g.allow {
+		if path == a || strings.HasPrefix(path, a+"/") {
+			return true
+		}
+	}
+
+	return false
+}
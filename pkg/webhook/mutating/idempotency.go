@@ -0,0 +1,128 @@
+package mutating
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// IdempotencyCheckPolicy is the policy of the mutation idempotency check. The check
+// executes the mutator a second time using the mutated object, if the second
+// mutation changes the object, the mutator is not idempotent. Not idempotent mutators
+// corrupt the objects when the apiserver reinvokes the webhooks (`reinvocationPolicy: IfNeeded`).
+type IdempotencyCheckPolicy string
+
+const (
+	// IdempotencyCheckPolicyDisabled will not check the mutations idempotency.
+	IdempotencyCheckPolicyDisabled IdempotencyCheckPolicy = "disabled"
+	// IdempotencyCheckPolicyWarn will log and measure the not idempotent mutations.
+	IdempotencyCheckPolicyWarn IdempotencyCheckPolicy = "warn"
+	// IdempotencyCheckPolicyFail will log, measure and fail the review of the not idempotent mutations.
+	IdempotencyCheckPolicyFail IdempotencyCheckPolicy = "fail"
+)
+
+// checkIdempotency executes the mutator again using the mutated object and checks that the mutator
+// doesn't change it again.
+func (w mutatingWebhook) checkIdempotency(ctx context.Context, ar model.AdmissionReview, mutatedJSON []byte) error {
+	logger := w.logger.WithCtxValues(ctx)
+
+	runtimeObj, err := w.objectCreator.NewObject(mutatedJSON)
+	if err != nil {
+		return fmt.Errorf("could not create object from mutated object: %w", err)
+	}
+	obj, ok := runtimeObj.(metav1.Object)
+	if !ok {
+		return fmt.Errorf("impossible to type assert the mutated object to metav1.Object")
+	}
+
+	patch, denied, err := w.remutate(ctx, ar, obj)
+	if err != nil {
+		if w.cfg.IdempotencyCheckPolicy == IdempotencyCheckPolicyFail {
+			return fmt.Errorf("could not mutate the mutated object: %w", err)
+		}
+		logger.Errorf("Idempotency check could not mutate the mutated object: %s", err)
+		return nil
+	}
+
+	idempotent := !denied && len(patch) == 0
+	w.metricsRecorder.MeasureMutationIdempotencyOp(ctx, webhook.MeasureMutationIdempotencyOpData{
+		WebhookID:              w.id,
+		AdmissionReviewVersion: string(ar.Version),
+		ResourceKind:           resourceKind(ar),
+		Operation:              string(ar.Operation),
+		Idempotent:             idempotent,
+	})
+
+	if idempotent {
+		return nil
+	}
+
+	var msg string
+	if denied {
+		msg = "mutator denied the already mutated object"
+	} else {
+		ops := make([]string, 0, len(patch))
+		for _, op := range patch {
+			ops = append(ops, fmt.Sprintf("%s %s", op.Operation, op.Path))
+		}
+		msg = fmt.Sprintf("mutator changed the already mutated object: %s", strings.Join(ops, ", "))
+	}
+	logger.WithValues(log.Kv{"idempotent": false}).Warningf("Not idempotent mutation: %s", msg)
+
+	if w.cfg.IdempotencyCheckPolicy == IdempotencyCheckPolicyFail {
+		return fmt.Errorf("not idempotent mutation: %s", msg)
+	}
+
+	return nil
+}
+
+// remutate mutates an already mutated object and returns the JSON patch of the mutation.
+func (w mutatingWebhook) remutate(ctx context.Context, ar model.AdmissionReview, obj metav1.Object) (patch []jsonpatch.Operation, denied bool, err error) {
+	unmutatedJSON, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not marshal into JSON the object: %w", err)
+	}
+
+	res, err := w.mutator.Mutate(ctx, &ar, obj)
+	if err != nil {
+		return nil, false, err
+	}
+	if res == nil {
+		return nil, false, fmt.Errorf("result is required, mutator result is nil")
+	}
+	if res.Denied {
+		return nil, true, nil
+	}
+
+	mutatedObj, err := resultMutatedObject(obj, res)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid mutator result: %w", err)
+	}
+	mutatedJSON, err := json.Marshal(mutatedObj)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not marshal into JSON mutated object: %w", err)
+	}
+
+	patch, err = jsonpatch.CreatePatch(unmutatedJSON, mutatedJSON)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not create JSON patch: %w", err)
+	}
+
+	return patch, false, nil
+}
+
+func resourceKind(ar model.AdmissionReview) string {
+	gvk := ar.RequestGVK
+	if gvk == nil {
+		return ""
+	}
+	return strings.Trim(strings.Join([]string{gvk.Group, gvk.Version, gvk.Kind}, "/"), "/")
+}
@@ -0,0 +1,83 @@
+package mutating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// MatchMutatorConfig is the configuration of a match mutator.
+type MatchMutatorConfig struct {
+	// Matcher is the matcher used to check the requests (e.g: `webhook.NewMatcher`).
+	Matcher webhook.Matcher
+	// Mutator is the mutator executed on the matched requests.
+	Mutator Mutator
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+}
+
+func (c *MatchMutatorConfig) defaults() error {
+	if c.Matcher == nil {
+		return fmt.Errorf("matcher is required")
+	}
+
+	if c.Mutator == nil {
+		return fmt.Errorf("mutator is required")
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	return nil
+}
+
+type matchMutator struct {
+	matcher webhook.Matcher
+	mutator Mutator
+	logger  log.Logger
+	tracer  tracing.Tracer
+}
+
+// NewMatchMutator returns a mutator that will only execute the wrapped mutator on the requests
+// that match, the rest of the requests will not be mutated.
+func NewMatchMutator(cfg MatchMutatorConfig) (Mutator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return matchMutator{
+		matcher: cfg.Matcher,
+		mutator: cfg.Mutator,
+		logger:  cfg.Logger,
+		tracer:  cfg.Tracer,
+	}, nil
+}
+
+func (m matchMutator) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
+	match, err := m.matcher.Match(ctx, ar, obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not match the request: %w", err)
+	}
+
+	if !match {
+		m.logger.WithCtxValues(ctx).Debugf("Request not matched, mutator skipped")
+		m.tracer.AddTraceEvent(ctx, "mutator skipped", map[string]interface{}{"reason": "request not matched"})
+		return &MutatorResult{}, nil
+	}
+
+	return m.mutator.Mutate(ctx, ar, obj)
+}
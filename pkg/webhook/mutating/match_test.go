@@ -0,0 +1,73 @@
+package mutating_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+)
+
+func TestMatchMutator(t *testing.T) {
+	tests := map[string]struct {
+		matcher   webhook.Matcher
+		expMutate bool
+		expErr    bool
+	}{
+		"Matched requests should be mutated.": {
+			matcher: webhook.MatcherFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (bool, error) {
+				return true, nil
+			}),
+			expMutate: true,
+		},
+
+		"Not matched requests should skip the mutation.": {
+			matcher: webhook.MatcherFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (bool, error) {
+				return false, nil
+			}),
+			expMutate: false,
+		},
+
+		"Failing matching the request should fail.": {
+			matcher: webhook.MatcherFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (bool, error) {
+				return false, fmt.Errorf("wanted")
+			}),
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mutated := false
+			m, err := mutating.NewMatchMutator(mutating.MatchMutatorConfig{
+				Matcher: test.matcher,
+				Mutator: mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+					mutated = true
+					return &mutating.MutatorResult{MutatedObject: obj}, nil
+				}),
+			})
+			require.NoError(err)
+
+			gotRes, err := m.Mutate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expMutate, mutated)
+				if !test.expMutate {
+					assert.Equal(&mutating.MutatorResult{}, gotRes)
+				}
+			}
+		})
+	}
+}
@@ -2,12 +2,15 @@ package mutating
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/slok/kubewebhook/v2/pkg/log"
 	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
 )
 
 // MutatorResult is the result of a mutator.
@@ -19,6 +22,29 @@ type MutatorResult struct {
 	MutatedObject metav1.Object
 	// Warnings are special messages that can be set to warn the user (e.g deprecation messages, almost invalid resources...).
 	Warnings []string
+	// AuditAnnotations will be added to the apiserver audit log of the request (e.g the reason of a mutation).
+	// Only supported on 'v1' admission reviews.
+	AuditAnnotations map[string]string
+	// Denied will deny the request instead of mutating it (e.g the object can't be fixed), in
+	// case there is a chain set, it will be stopped.
+	Denied bool
+	// Message is the message returned to the user when the request is denied.
+	Message string
+	// Code is the status code used when the request is denied, if not set it will be 400 (Bad Request).
+	Code int32
+	// Reason is the machine readable status reason used when the request is denied.
+	Reason metav1.StatusReason
+	// MutatorPatches are the JSON patches applied by each of the mutators, set by the chain
+	// when patch attribution is enabled.
+	MutatorPatches []model.MutatorPatch
+	// JSONPatch is a JSON patch (RFC 6902) that will be applied to the mutated object, instead of returning
+	// a mutated object (e.g: `[{"op":"add","path":"/metadata/labels/app","value":"my-app"}]`).
+	JSONPatch []byte
+	// MergePatch is a JSON merge patch (RFC 7386) that will be applied to the mutated object, after the JSON patch.
+	MergePatch []byte
+	// StrategicMergePatch is a Kubernetes strategic merge patch that will be applied to the mutated object, after
+	// the JSON merge patch. Only supported on typed objects (not on unstructured objects).
+	StrategicMergePatch []byte
 }
 
 // Mutator knows how to mutate the received kubernetes object.
@@ -28,10 +54,15 @@ type Mutator interface {
 	// as result.MutatedObject is the object that will be used as the mutation.
 	// It must be of the same type of the received one (if is a Pod, it must return a Pod)
 	// if no object is returned, it will be used the received one as the mutated one.
+	// Instead of an object, the mutator can return the mutation as a patch (JSON patch,
+	// JSON merge patch or strategic merge patch), that will be applied to the object.
 	// Also receives the webhook admission review in case it wants more context and
 	// information of the review.
 	// Mutators can be grouped in chains, that's why we have a `StopChain` boolean
 	// in the result, to stop executing the validators chain.
+	// If the object can't be mutated, the mutator can deny the request using the
+	// `Denied` field of the result.
+	// On update operations, the decoded old object is available using `webhook.OldObjectFromCtx`.
 	Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (result *MutatorResult, err error)
 }
 
@@ -48,26 +79,107 @@ func (f MutatorFunc) Mutate(ctx context.Context, ar *model.AdmissionReview, obj
 // Chain is a chain of mutators that will execute secuentially all the
 // mutators that have been added to it. It satisfies Mutator interface.
 type Chain struct {
-	mutators []Mutator
-	logger   log.Logger
+	mutators            []Mutator
+	logger              log.Logger
+	tracer              tracing.Tracer
+	patchAttribution    bool
+	patchConflictPolicy PatchConflictPolicy
 }
 
 // NewChain returns a new chain.
 func NewChain(logger log.Logger, mutators ...Mutator) *Chain {
+	if logger == nil {
+		logger = log.Noop
+	}
+
 	return &Chain{
 		mutators: mutators,
 		logger:   logger,
+		tracer:   tracing.Noop,
+	}
+}
+
+// ChainConfig is the configuration of a mutator chain.
+type ChainConfig struct {
+	// Mutators are the mutators of the chain, executed in order. Use `NewNamedMutator` to
+	// identify them on the patch attribution.
+	Mutators []Mutator
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+	// PatchAttribution will compute the JSON patch of each of the mutators of the chain,
+	// this patches will be logged, traced and returned on the result (`MutatorPatches`).
+	// It has a performance penalty because the object is marshaled after each mutator.
+	PatchAttribution bool
+	// PatchConflictPolicy is the policy applied when a mutator changes a path already changed by a
+	// previous mutator of the chain. Only used when PatchAttribution is enabled. By default ignored.
+	PatchConflictPolicy PatchConflictPolicy
+}
+
+func (c *ChainConfig) defaults() error {
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
 	}
+
+	switch c.PatchConflictPolicy {
+	case "":
+		c.PatchConflictPolicy = PatchConflictPolicyIgnore
+	case PatchConflictPolicyIgnore, PatchConflictPolicyWarn, PatchConflictPolicyFail:
+	default:
+		return fmt.Errorf("unknown patch conflict policy %q", c.PatchConflictPolicy)
+	}
+
+	if c.PatchConflictPolicy != PatchConflictPolicyIgnore && !c.PatchAttribution {
+		return fmt.Errorf("patch conflict policy requires patch attribution")
+	}
+
+	return nil
+}
+
+// NewChainWithConfig returns a new chain using a configuration.
+func NewChainWithConfig(cfg ChainConfig) (*Chain, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &Chain{
+		mutators:            cfg.Mutators,
+		logger:              cfg.Logger,
+		tracer:              cfg.Tracer,
+		patchAttribution:    cfg.PatchAttribution,
+		patchConflictPolicy: cfg.PatchConflictPolicy,
+	}, nil
 }
 
 // Mutate will execute all the mutation chain.
 func (c *Chain) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
 	var warnings []string
-	for _, mt := range c.mutators {
+	var auditAnnotations map[string]string
+	var attr *patchAttributor
+	if c.patchAttribution {
+		attr = newPatchAttributor(c.patchConflictPolicy)
+	}
+
+	for i, mt := range c.mutators {
 		select {
 		case <-ctx.Done():
 			return nil, fmt.Errorf("mutator chain not finished correctly, context done")
 		default:
+			var before []byte
+			if attr != nil {
+				b, err := json.Marshal(obj)
+				if err != nil {
+					return nil, fmt.Errorf("could not marshal into JSON the object before the mutator: %w", err)
+				}
+				before = b
+			}
+
 			res, err := mt.Mutate(ctx, ar, obj)
 			if err != nil {
 				return nil, err
@@ -77,21 +189,66 @@ func (c *Chain) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav
 				return nil, fmt.Errorf("validator result can't be `nil`")
 			}
 
-			// Don't lose the data through the chain, set warnings and pass around the mutated object.
+			// Don't lose the data through the chain, set warnings, audit annotations and pass around the mutated object.
 			warnings = append(warnings, res.Warnings...)
-			if res.MutatedObject != nil {
-				obj = res.MutatedObject
+			auditAnnotations = mergeAuditAnnotations(auditAnnotations, res.AuditAnnotations)
+			obj, err = resultMutatedObject(obj, res)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %q mutator result: %w", mutatorName(i, mt), err)
 			}
 
-			if res.StopChain {
+			if attr != nil {
+				name := mutatorName(i, mt)
+				mp, conflicts, err := attr.add(name, before, obj)
+				if err != nil {
+					return nil, err
+				}
+				c.logger.WithCtxValues(ctx).WithValues(log.Kv{"mutator": name}).Debugf("Mutator patch: '%s'", string(mp.JSONPatchPatch))
+				c.tracer.AddTraceEvent(ctx, "mutator patch", map[string]interface{}{"mutator": name, "patch": string(mp.JSONPatchPatch)})
+
+				if len(conflicts) > 0 {
+					msg := fmt.Sprintf("mutator %q changed paths already changed by previous mutators: %s", name, strings.Join(conflicts, ", "))
+					if c.patchConflictPolicy == PatchConflictPolicyFail {
+						return nil, fmt.Errorf("patch conflict: %s", msg)
+					}
+					c.logger.WithCtxValues(ctx).Warningf("Patch conflict: %s", msg)
+					warnings = append(warnings, msg)
+				}
+			}
+
+			if res.StopChain || res.Denied {
+				res.MutatedObject = obj
+				res.JSONPatch, res.MergePatch, res.StrategicMergePatch = nil, nil, nil
 				res.Warnings = warnings
+				res.AuditAnnotations = auditAnnotations
+				res.MutatorPatches = attr.patches()
 				return res, nil
 			}
 		}
 	}
 
 	return &MutatorResult{
-		MutatedObject: obj,
-		Warnings:      warnings,
+		MutatedObject:    obj,
+		Warnings:         warnings,
+		AuditAnnotations: auditAnnotations,
+		MutatorPatches:   attr.patches(),
 	}, nil
 }
+
+// mergeAuditAnnotations merges the new audit annotations into the current ones, the new ones
+// have priority.
+func mergeAuditAnnotations(current, new map[string]string) map[string]string {
+	if len(new) == 0 {
+		return current
+	}
+
+	merged := make(map[string]string, len(current)+len(new))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range new {
+		merged[k] = v
+	}
+
+	return merged
+}
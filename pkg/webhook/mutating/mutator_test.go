@@ -7,10 +7,12 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating/mutatingmock"
 )
@@ -82,6 +84,46 @@ func TestMutatorChain(t *testing.T) {
 			expResult: &mutating.MutatorResult{StopChain: true},
 		},
 
+		"Audit annotations should be merged through the chain, the latest mutators have priority.": {
+			mutatorMocks: func() []mutating.Mutator {
+				m1, m2, m3 := &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}
+				m1.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2"}}, nil)
+				m2.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{}, nil)
+				m3.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{AuditAnnotations: map[string]string{"k2": "v2.5", "k3": "v3"}}, nil)
+				return []mutating.Mutator{m1, m2, m3}
+			},
+			expResult: &mutating.MutatorResult{
+				AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2.5", "k3": "v3"},
+			},
+		},
+
+		"Audit annotations shouldn't be lost in the chain (stopped chain by stop chain flag).": {
+			mutatorMocks: func() []mutating.Mutator {
+				m1, m2, m3 := &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}
+				m1.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{AuditAnnotations: map[string]string{"k1": "v1"}}, nil)
+				m2.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{StopChain: true, AuditAnnotations: map[string]string{"k2": "v2"}}, nil)
+				return []mutating.Mutator{m1, m2, m3}
+			},
+			expResult: &mutating.MutatorResult{
+				StopChain:        true,
+				AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2"},
+			},
+		},
+
+		"Should stop in the middle of the chain if any of the mutators denies the request, without losing the chain data.": {
+			mutatorMocks: func() []mutating.Mutator {
+				m1, m2, m3 := &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}
+				m1.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{Warnings: []string{"w1"}}, nil)
+				m2.On("Mutate", mock.Anything, mock.Anything, mock.Anything).Return(&mutating.MutatorResult{Denied: true, Message: "can't be fixed", Warnings: []string{"w2"}}, nil)
+				return []mutating.Mutator{m1, m2, m3}
+			},
+			expResult: &mutating.MutatorResult{
+				Denied:   true,
+				Message:  "can't be fixed",
+				Warnings: []string{"w1", "w2"},
+			},
+		},
+
 		"In case of error the chain should be stopped.": {
 			mutatorMocks: func() []mutating.Mutator {
 				m1, m2, m3, m4, m5 := &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}, &mutatingmock.Mutator{}
@@ -120,3 +162,261 @@ func TestMutatorChain(t *testing.T) {
 		})
 	}
 }
+
+func TestMutatorChainPatchAttribution(t *testing.T) {
+	labelMutator := func(k, v string) mutating.Mutator {
+		return mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+			labels := obj.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[k] = v
+			obj.SetLabels(labels)
+			return &mutating.MutatorResult{}, nil
+		})
+	}
+
+	deleteLabelMutator := func(k string) mutating.Mutator {
+		return mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+			labels := obj.GetLabels()
+			delete(labels, k)
+			obj.SetLabels(labels)
+			return &mutating.MutatorResult{}, nil
+		})
+	}
+
+	tests := map[string]struct {
+		cfg          mutating.ChainConfig
+		labels       map[string]string
+		expResult    *mutating.MutatorResult
+		expConfigErr bool
+		expErr       bool
+	}{
+		"Having patch attribution, the patches of each mutator should be returned.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution: true,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", labelMutator("k2", "v2")),
+					labelMutator("k3", "v3"),
+				},
+			},
+			expResult: &mutating.MutatorResult{
+				MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"k0": "v0", "k1": "v1", "k2": "v2", "k3": "v3"}}},
+				MutatorPatches: []model.MutatorPatch{
+					{Mutator: "m1", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k1","value":"v1"}]`)},
+					{Mutator: "m2", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k2","value":"v2"}]`)},
+					{Mutator: "mutator-2", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k3","value":"v3"}]`)},
+				},
+			},
+		},
+
+		"Having patch attribution with a warning conflict policy, the conflicts should be returned as warnings.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution:    true,
+				PatchConflictPolicy: mutating.PatchConflictPolicyWarn,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", labelMutator("k1", "v2")),
+				},
+			},
+			expResult: &mutating.MutatorResult{
+				MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"k0": "v0", "k1": "v2"}}},
+				MutatorPatches: []model.MutatorPatch{
+					{Mutator: "m1", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k1","value":"v1"}]`)},
+					{Mutator: "m2", JSONPatchPatch: []byte(`[{"op":"replace","path":"/metadata/labels/k1","value":"v2"}]`)},
+				},
+				Warnings: []string{`mutator "m2" changed paths already changed by previous mutators: /metadata/labels/k1 (m1)`},
+			},
+		},
+
+		"Having patch attribution with a fail conflict policy, the conflicts should fail the chain.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution:    true,
+				PatchConflictPolicy: mutating.PatchConflictPolicyFail,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", labelMutator("k1", "v2")),
+				},
+			},
+			expErr: true,
+		},
+
+		"Having patch attribution with a fail conflict policy, removing a path changed by previous mutators should fail the chain.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution:    true,
+				PatchConflictPolicy: mutating.PatchConflictPolicyFail,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", deleteLabelMutator("k1")),
+				},
+			},
+			expErr: true,
+		},
+
+		"Having patch attribution with a fail conflict policy and without initial labels, adding labels shouldn't fail.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution:    true,
+				PatchConflictPolicy: mutating.PatchConflictPolicyFail,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", labelMutator("k2", "v2")),
+				},
+			},
+			labels: map[string]string{},
+			expResult: &mutating.MutatorResult{
+				MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"k1": "v1", "k2": "v2"}}},
+				MutatorPatches: []model.MutatorPatch{
+					{Mutator: "m1", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels","value":{"k1":"v1"}}]`)},
+					{Mutator: "m2", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k2","value":"v2"}]`)},
+				},
+			},
+		},
+
+		"Having patch attribution with a fail conflict policy and without conflicts, shouldn't fail.": {
+			cfg: mutating.ChainConfig{
+				PatchAttribution:    true,
+				PatchConflictPolicy: mutating.PatchConflictPolicyFail,
+				Mutators: []mutating.Mutator{
+					mutating.NewNamedMutator("m1", labelMutator("k1", "v1")),
+					mutating.NewNamedMutator("m2", labelMutator("k2", "v2")),
+				},
+			},
+			expResult: &mutating.MutatorResult{
+				MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"k0": "v0", "k1": "v1", "k2": "v2"}}},
+				MutatorPatches: []model.MutatorPatch{
+					{Mutator: "m1", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k1","value":"v1"}]`)},
+					{Mutator: "m2", JSONPatchPatch: []byte(`[{"op":"add","path":"/metadata/labels/k2","value":"v2"}]`)},
+				},
+			},
+		},
+
+		"Having a conflict policy without patch attribution, should fail.": {
+			cfg:          mutating.ChainConfig{PatchConflictPolicy: mutating.PatchConflictPolicyWarn},
+			expConfigErr: true,
+		},
+
+		"Having an unknown conflict policy, should fail.": {
+			cfg:          mutating.ChainConfig{PatchAttribution: true, PatchConflictPolicy: "unknown"},
+			expConfigErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			chain, err := mutating.NewChainWithConfig(test.cfg)
+			if test.expConfigErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			labels := test.labels
+			if labels == nil {
+				labels = map[string]string{"k0": "v0"}
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+			res, err := chain.Mutate(context.TODO(), nil, pod)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, res)
+			}
+		})
+	}
+}
+
+func TestMutatorChainPatches(t *testing.T) {
+	tests := map[string]struct {
+		initialObj metav1.Object
+		mutators   []mutating.Mutator
+		expObj     metav1.Object
+		expErr     bool
+	}{
+		"Patches of the mutators should be applied and composed through the chain.": {
+			initialObj: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "p0"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "c1", Image: "i1"}}},
+			},
+			mutators: []mutating.Mutator{
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{JSONPatch: []byte(`[{"op":"add","path":"/metadata/labels","value":{"l1":"v1"}}]`)}, nil
+				}),
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{MergePatch: []byte(`{"metadata":{"labels":{"l2":"v2"}}}`)}, nil
+				}),
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{StrategicMergePatch: []byte(`{"spec":{"containers":[{"name":"c2","image":"i2"}]}}`)}, nil
+				}),
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+					// Should receive the patched object.
+					obj.SetAnnotations(map[string]string{"labels": fmt.Sprintf("%d", len(obj.GetLabels()))})
+					return &mutating.MutatorResult{MutatedObject: obj}, nil
+				}),
+			},
+			expObj: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "p0",
+					Labels:      map[string]string{"l1": "v1", "l2": "v2"},
+					Annotations: map[string]string{"labels": "2"},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c2", Image: "i2"}, {Name: "c1", Image: "i1"}}},
+			},
+		},
+
+		"Patches should be applied to the returned mutated object.": {
+			initialObj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p0"}},
+			mutators: []mutating.Mutator{
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{
+						MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1"}},
+						MergePatch:    []byte(`{"metadata":{"namespace":"ns1"}}`),
+					}, nil
+				}),
+			},
+			expObj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "ns1"}},
+		},
+
+		"Patches of a mutator that stops the chain should be applied.": {
+			initialObj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p0"}},
+			mutators: []mutating.Mutator{
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{StopChain: true, JSONPatch: []byte(`[{"op":"replace","path":"/metadata/name","value":"p1"}]`)}, nil
+				}),
+			},
+			expObj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1"}},
+		},
+
+		"Invalid patches should fail the chain.": {
+			initialObj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p0"}},
+			mutators: []mutating.Mutator{
+				mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{JSONPatch: []byte(`[{"op":"remove","path":"/metadata/labels/missing"}]`)}, nil
+				}),
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			chain := mutating.NewChain(log.Noop, test.mutators...)
+			gotRes, err := chain.Mutate(context.TODO(), &model.AdmissionReview{}, test.initialObj)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expObj, gotRes.MutatedObject)
+				assert.Empty(gotRes.JSONPatch)
+				assert.Empty(gotRes.MergePatch)
+				assert.Empty(gotRes.StrategicMergePatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package mutating
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	jsonpatchapply "gopkg.in/evanphx/json-patch.v4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// hasPatches returns true if the result has any of the mutation patches set.
+func (r *MutatorResult) hasPatches() bool {
+	return len(r.JSONPatch) > 0 || len(r.MergePatch) > 0 || len(r.StrategicMergePatch) > 0
+}
+
+// resultMutatedObject returns the mutated object of a mutator result, this is the result mutated object
+// (or the received one if missing) with the result patches applied in order: JSON patch, JSON merge patch
+// and strategic merge patch.
+func resultMutatedObject(obj metav1.Object, res *MutatorResult) (metav1.Object, error) {
+	if res.MutatedObject != nil {
+		obj = res.MutatedObject
+	}
+
+	if !res.hasPatches() {
+		return obj, nil
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal into JSON the object to patch: %w", err)
+	}
+
+	if len(res.JSONPatch) > 0 {
+		patch, err := jsonpatchapply.DecodePatch(res.JSONPatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON patch: %w", err)
+		}
+
+		data, err = patch.Apply(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply JSON patch: %w", err)
+		}
+	}
+
+	if len(res.MergePatch) > 0 {
+		data, err = jsonpatchapply.MergePatch(data, res.MergePatch)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply JSON merge patch: %w", err)
+		}
+	}
+
+	if len(res.StrategicMergePatch) > 0 {
+		// Strategic merge patches need the Go type information (patch strategies, merge keys...).
+		if _, ok := obj.(runtime.Unstructured); ok {
+			return nil, fmt.Errorf("strategic merge patches are not supported on unstructured objects")
+		}
+
+		data, err = strategicpatch.StrategicMergePatch(data, res.StrategicMergePatch, obj)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply strategic merge patch: %w", err)
+		}
+	}
+
+	// Decode on a new object of the same type, decoding on the same object would
+	// keep the removed fields.
+	t := reflect.TypeOf(obj)
+	if t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("object must be a pointer to be patched")
+	}
+	patchedObj, ok := reflect.New(t.Elem()).Interface().(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("impossible to type assert the patched object to metav1.Object")
+	}
+
+	err = json.Unmarshal(data, patchedObj)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal JSON into the patched object: %w", err)
+	}
+
+	return patchedObj, nil
+}
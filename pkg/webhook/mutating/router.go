@@ -0,0 +1,99 @@
+package mutating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// KindRouterConfig is the configuration of a kind router.
+type KindRouterConfig struct {
+	// Resources are the mutators of the request resources (and subresources), these have
+	// priority over the kinds.
+	Resources map[webhook.RouteResource]Mutator
+	// Kinds are the mutators of the request kinds.
+	Kinds map[metav1.GroupVersionKind]Mutator
+	// Fallback is the mutator used when the request doesn't match any resource or kind, if not
+	// set, these requests will fail.
+	Fallback Mutator
+	// Logger is the logger.
+	Logger log.Logger
+}
+
+func (c *KindRouterConfig) defaults() error {
+	if len(c.Resources) == 0 && len(c.Kinds) == 0 && c.Fallback == nil {
+		return fmt.Errorf("at least one route is required")
+	}
+
+	for r, m := range c.Resources {
+		if m == nil {
+			return fmt.Errorf("%q resource mutator is required", r)
+		}
+	}
+
+	for k, m := range c.Kinds {
+		if m == nil {
+			return fmt.Errorf("%q kind mutator is required", k)
+		}
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	return nil
+}
+
+type kindRouter struct {
+	resources map[webhook.RouteResource]Mutator
+	kinds     map[metav1.GroupVersionKind]Mutator
+	fallback  Mutator
+	logger    log.Logger
+}
+
+// NewKindRouter returns a mutator that routes the requests to different mutators based on the request
+// resource (and subresource) or kind. This is useful to serve multiple types on the same webhook
+// using the dynamic object type (without `WebhookConfig.Obj`).
+func NewKindRouter(cfg KindRouterConfig) (Mutator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return kindRouter{
+		resources: cfg.Resources,
+		kinds:     cfg.Kinds,
+		fallback:  cfg.Fallback,
+		logger:    cfg.Logger,
+	}, nil
+}
+
+func (k kindRouter) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
+	logger := k.logger.WithCtxValues(ctx)
+
+	if r, ok := webhook.RequestRouteResource(*ar); ok {
+		if m, ok := k.resources[r]; ok {
+			logger.Debugf("Request routed to %q resource mutator", r)
+			return m.Mutate(ctx, ar, obj)
+		}
+	}
+
+	if gvk, ok := webhook.RequestRouteKind(*ar); ok {
+		if m, ok := k.kinds[gvk]; ok {
+			logger.Debugf("Request routed to %q kind mutator", gvk)
+			return m.Mutate(ctx, ar, obj)
+		}
+	}
+
+	if k.fallback == nil {
+		return nil, fmt.Errorf("no mutator for the request resource or kind")
+	}
+
+	logger.Debugf("Request routed to fallback mutator")
+	return k.fallback.Mutate(ctx, ar, obj)
+}
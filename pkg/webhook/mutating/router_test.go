@@ -0,0 +1,149 @@
+package mutating_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+)
+
+func getWarnMutator(warning string) mutating.Mutator {
+	return mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+		return &mutating.MutatorResult{Warnings: []string{warning}}, nil
+	})
+}
+
+func TestKindRouter(t *testing.T) {
+	podsGVR := &metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	podGVK := &metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}
+	deployGVK := &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	deployV1beta1GVK := &metav1.GroupVersionKind{Group: "apps", Version: "v1beta1", Kind: "Deployment"}
+	deploysGVR := &metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	deploysV1beta1GVR := &metav1.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "deployments"}
+
+	tests := map[string]struct {
+		cfg         mutating.KindRouterConfig
+		review      model.AdmissionReview
+		expWarnings []string
+		expErr      bool
+		expCfgErr   bool
+	}{
+		"A request should be routed by its kind.": {
+			cfg: mutating.KindRouterConfig{
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{
+					*podGVK:    getWarnMutator("pod"),
+					*deployGVK: getWarnMutator("deployment"),
+				},
+			},
+			review:      model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: deployGVK},
+			expWarnings: []string{"deployment"},
+		},
+
+		"A converted request should be routed by its converted kind.": {
+			cfg: mutating.KindRouterConfig{
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{
+					*deployGVK:        getWarnMutator("deployment"),
+					*deployV1beta1GVK: getWarnMutator("deployment-v1beta1"),
+				},
+			},
+			review:      model.AdmissionReview{GVK: deployGVK, RequestGVK: deployV1beta1GVK},
+			expWarnings: []string{"deployment"},
+		},
+
+		"A converted request should be routed by its converted resource.": {
+			cfg: mutating.KindRouterConfig{
+				Resources: map[webhook.RouteResource]mutating.Mutator{
+					{Group: "apps", Version: "v1", Resource: "deployments"}:      getWarnMutator("deployments"),
+					{Group: "apps", Version: "v1beta1", Resource: "deployments"}: getWarnMutator("deployments-v1beta1"),
+				},
+			},
+			review:      model.AdmissionReview{GVR: deploysGVR, RequestGVR: deploysV1beta1GVR},
+			expWarnings: []string{"deployments"},
+		},
+
+		"A request should be routed by its resource before its kind.": {
+			cfg: mutating.KindRouterConfig{
+				Resources: map[webhook.RouteResource]mutating.Mutator{
+					{Version: "v1", Resource: "pods"}: getWarnMutator("pods"),
+				},
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{
+					*podGVK: getWarnMutator("pod"),
+				},
+			},
+			review:      model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: podGVK},
+			expWarnings: []string{"pods"},
+		},
+
+		"A request should be routed by its resource and subresource.": {
+			cfg: mutating.KindRouterConfig{
+				Resources: map[webhook.RouteResource]mutating.Mutator{
+					{Version: "v1", Resource: "pods"}:                        getWarnMutator("pods"),
+					{Version: "v1", Resource: "pods", SubResource: "status"}: getWarnMutator("pods-status"),
+				},
+			},
+			review:      model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: podGVK, RequestSubResource: "status"},
+			expWarnings: []string{"pods-status"},
+		},
+
+		"A not matched request should be routed to the fallback.": {
+			cfg: mutating.KindRouterConfig{
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{
+					*podGVK: getWarnMutator("pod"),
+				},
+				Fallback: getWarnMutator("fallback"),
+			},
+			review:      model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: deployGVK},
+			expWarnings: []string{"fallback"},
+		},
+
+		"A not matched request without fallback should fail.": {
+			cfg: mutating.KindRouterConfig{
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{
+					*podGVK: getWarnMutator("pod"),
+				},
+			},
+			review: model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: deployGVK},
+			expErr: true,
+		},
+
+		"A router without routes should fail the configuration.": {
+			cfg:       mutating.KindRouterConfig{},
+			expCfgErr: true,
+		},
+
+		"A router with a nil mutator should fail the configuration.": {
+			cfg: mutating.KindRouterConfig{
+				Kinds: map[metav1.GroupVersionKind]mutating.Mutator{*podGVK: nil},
+			},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			router, err := mutating.NewKindRouter(test.cfg)
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			gotRes, err := router.Mutate(context.TODO(), &test.review, &corev1.Pod{})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expWarnings, gotRes.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package mutating
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/internal/helpers"
+)
+
+// TimeoutMutatorConfig is the configuration of a timeout mutator.
+type TimeoutMutatorConfig struct {
+	// Name is the name of the mutator, used on the logs, traces, metrics and chains.
+	Name string
+	// Mutator is the wrapped mutator.
+	Mutator Mutator
+	// Timeout is the maximum duration of the mutator.
+	Timeout time.Duration
+	// TimeoutPolicy is the policy applied when the mutator times out. By default error.
+	TimeoutPolicy webhook.StepTimeoutPolicy
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+	// MetricsRecorder is used to measure the timeouts. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+func (c *TimeoutMutatorConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.Mutator == nil {
+		return fmt.Errorf("mutator is required")
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	if c.TimeoutPolicy == "" {
+		c.TimeoutPolicy = webhook.StepTimeoutPolicyError
+	}
+	if err := c.TimeoutPolicy.Validate(); err != nil {
+		return err
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"mutator": c.Name})
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+type timeoutMutator struct {
+	cfg        TimeoutMutatorConfig
+	metricsRec webhook.StepTimeoutMetricsRecorder
+}
+
+// NewTimeoutMutator returns a mutator that limits the duration of the wrapped mutator, applying
+// the timeout policy when reached. This can be used to set per mutator timeouts on chains.
+//
+// The wrapped mutator receives a copy of the object, so the object is not changed after a timeout.
+func NewTimeoutMutator(cfg TimeoutMutatorConfig) (NamedMutator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return timeoutMutator{
+		cfg:        cfg,
+		metricsRec: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+	}, nil
+}
+
+func (t timeoutMutator) Name() string { return t.cfg.Name }
+
+func (t timeoutMutator) Mutate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
+	// Don't share the object with the mutator, it could be mutated after the timeout.
+	rObj, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("impossible to type assert the object to runtime.Object")
+	}
+	objCopy, ok := rObj.DeepCopyObject().(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("impossible to type assert the deep copy to metav1.Object")
+	}
+
+	res, timedOut, err := helpers.RunWithTimeout(ctx, t.cfg.Timeout, func(ctx context.Context) (*MutatorResult, error) {
+		return t.cfg.Mutator.Mutate(ctx, ar, objCopy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !timedOut {
+		if res != nil && res.MutatedObject == nil {
+			res.MutatedObject = objCopy
+		}
+		return res, nil
+	}
+
+	// Timeout.
+	policy := t.cfg.TimeoutPolicy
+	msg := webhook.StepTimeoutMessage(t.cfg.Name, t.cfg.Timeout)
+	t.cfg.Logger.WithCtxValues(ctx).WithValues(log.Kv{"timeout-policy": policy}).Warningf("Mutator timed out: %s", msg)
+	t.cfg.Tracer.AddTraceEvent(ctx, "step timeout", map[string]interface{}{
+		"step":           t.cfg.Name,
+		"timeout":        t.cfg.Timeout.String(),
+		"timeout_policy": policy,
+	})
+	t.metricsRec.MeasureStepTimeoutOp(ctx, webhook.MeasureStepTimeoutOpData{
+		StepName:      t.cfg.Name,
+		WebhookType:   model.WebhookKindMutating,
+		TimeoutPolicy: string(policy),
+	})
+
+	switch policy {
+	case webhook.StepTimeoutPolicySkip:
+		return &MutatorResult{}, nil
+	case webhook.StepTimeoutPolicyDeny:
+		return &MutatorResult{
+			Denied:  true,
+			Message: msg,
+			Code:    http.StatusGatewayTimeout,
+			Reason:  metav1.StatusReasonTimeout,
+		}, nil
+	default:
+		return nil, webhook.NewStepTimeoutError(t.cfg.Name, t.cfg.Timeout)
+	}
+}
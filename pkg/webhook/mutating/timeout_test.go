@@ -0,0 +1,154 @@
+package mutating_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestTimeoutMutator(t *testing.T) {
+	// Mutates the object after the timeout.
+	slowMutator := mutating.MutatorFunc(func(ctx context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		<-ctx.Done()
+		obj.SetName("slow")
+		return &mutating.MutatorResult{}, nil
+	})
+	fastMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		obj.SetName("fast")
+		return &mutating.MutatorResult{}, nil
+	})
+
+	tests := map[string]struct {
+		cfg        mutating.TimeoutMutatorConfig
+		expTimeout bool
+		expResult  *mutating.MutatorResult
+		expErr     bool
+		expCfgErr  bool
+	}{
+		"A mutator that finishes on time should mutate.": {
+			cfg:       mutating.TimeoutMutatorConfig{Mutator: fastMutator, Timeout: time.Second},
+			expResult: &mutating.MutatorResult{MutatedObject: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "fast"}}},
+		},
+
+		"A mutator that times out with skip policy should not mutate.": {
+			cfg:        mutating.TimeoutMutatorConfig{Mutator: slowMutator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicySkip},
+			expTimeout: true,
+			expResult:  &mutating.MutatorResult{},
+		},
+
+		"A mutator that times out with deny policy should deny.": {
+			cfg:        mutating.TimeoutMutatorConfig{Mutator: slowMutator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicyDeny},
+			expTimeout: true,
+			expResult: &mutating.MutatorResult{
+				Denied:  true,
+				Message: `"test" step timed out after 10ms`,
+				Code:    504,
+				Reason:  metav1.StatusReasonTimeout,
+			},
+		},
+
+		"A mutator that times out with error policy should fail.": {
+			cfg:        mutating.TimeoutMutatorConfig{Mutator: slowMutator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicyError},
+			expTimeout: true,
+			expErr:     true,
+		},
+
+		"A mutator without timeout should fail the configuration.": {
+			cfg:       mutating.TimeoutMutatorConfig{Mutator: fastMutator},
+			expCfgErr: true,
+		},
+
+		"A mutator with an invalid policy should fail the configuration.": {
+			cfg:       mutating.TimeoutMutatorConfig{Mutator: fastMutator, Timeout: time.Second, TimeoutPolicy: "unknown"},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			if test.expTimeout {
+				mrec.On("MeasureStepTimeoutOp", mock.Anything, webhook.MeasureStepTimeoutOpData{
+					StepName:      "test",
+					WebhookType:   "mutating",
+					TimeoutPolicy: string(test.cfg.TimeoutPolicy),
+				}).Once().Return()
+			}
+
+			test.cfg.Name = "test"
+			test.cfg.MetricsRecorder = mrec
+			m, err := mutating.NewTimeoutMutator(test.cfg)
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("test", m.Name())
+
+			pod := &corev1.Pod{}
+			gotRes, err := m.Mutate(context.TODO(), &model.AdmissionReview{}, pod)
+			if test.expErr {
+				assert.ErrorIs(err, webhook.ErrStepTimeout)
+				assert.EqualError(err, `"test" step timed out after 10ms`)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, gotRes)
+			}
+
+			// The received object should never be changed.
+			assert.Equal(&corev1.Pod{}, pod)
+		})
+	}
+}
+
+func TestTimeoutMutatorOnChain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	slow, err := mutating.NewTimeoutMutator(mutating.TimeoutMutatorConfig{
+		Name: "slow",
+		Mutator: mutating.MutatorFunc(func(ctx context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+			<-ctx.Done()
+			obj.SetLabels(map[string]string{"slow": "true"})
+			return &mutating.MutatorResult{}, nil
+		}),
+		Timeout:       10 * time.Millisecond,
+		TimeoutPolicy: webhook.StepTimeoutPolicySkip,
+	})
+	require.NoError(err)
+
+	fast, err := mutating.NewTimeoutMutator(mutating.TimeoutMutatorConfig{
+		Name: "fast",
+		Mutator: mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+			obj.SetAnnotations(map[string]string{"fast": "true"})
+			return &mutating.MutatorResult{}, nil
+		}),
+		Timeout: time.Second,
+	})
+	require.NoError(err)
+
+	chain, err := mutating.NewChainWithConfig(mutating.ChainConfig{Mutators: []mutating.Mutator{fast, slow}, PatchAttribution: true})
+	require.NoError(err)
+
+	gotRes, err := chain.Mutate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+	require.NoError(err)
+
+	assert.Equal(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"fast": "true"}}}, gotRes.MutatedObject)
+	if assert.Len(gotRes.MutatorPatches, 2) {
+		assert.Equal("fast", gotRes.MutatorPatches[0].Mutator)
+		assert.Equal("slow", gotRes.MutatorPatches[1].Mutator)
+	}
+}
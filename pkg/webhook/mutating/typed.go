@@ -0,0 +1,120 @@
+package mutating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/internal/helpers"
+)
+
+// TypedMutator is the same as a Mutator but it receives the object already typed
+// (e.g: `*corev1.Pod`), so the user doesn't need to type assert it.
+//
+// If the result has a `MutatedObject`, it must be of the same type.
+// On update operations, the typed old object is available using `webhook.TypedOldObjectFromCtx`.
+type TypedMutator[T metav1.Object] interface {
+	Mutate(ctx context.Context, ar *model.AdmissionReview, obj T) (result *MutatorResult, err error)
+}
+
+// TypedMutatorFunc is a helper type to create typed mutators from functions.
+type TypedMutatorFunc[T metav1.Object] func(context.Context, *model.AdmissionReview, T) (*MutatorResult, error)
+
+// Mutate satisfies TypedMutator interface.
+func (f TypedMutatorFunc[T]) Mutate(ctx context.Context, ar *model.AdmissionReview, obj T) (*MutatorResult, error) {
+	return f(ctx, ar, obj)
+}
+
+// NewTypedChain returns a new typed chain of mutators, it has the same behavior
+// as the regular Chain.
+func NewTypedChain[T metav1.Object](logger log.Logger, mutators ...TypedMutator[T]) TypedMutator[T] {
+	ms := make([]Mutator, 0, len(mutators))
+	for _, m := range mutators {
+		ms = append(ms, FromTyped(m))
+	}
+
+	return typedChain[T]{chain: NewChain(logger, ms...)}
+}
+
+type typedChain[T metav1.Object] struct {
+	chain *Chain
+}
+
+func (c typedChain[T]) Mutate(ctx context.Context, ar *model.AdmissionReview, obj T) (*MutatorResult, error) {
+	return c.chain.Mutate(ctx, ar, obj)
+}
+
+// FromTyped converts a typed mutator into a regular Mutator, this can be used to use typed mutators
+// in regular chains or webhooks. If the received object is not of the mutator type, it will return a
+// `webhook.InvalidObjectTypeError`.
+func FromTyped[T metav1.Object](m TypedMutator[T]) Mutator {
+	return MutatorFunc(func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
+		tObj, ok := obj.(T)
+		if !ok {
+			var zero T
+			return nil, &webhook.InvalidObjectTypeError{Expected: fmt.Sprintf("%T", zero), Got: fmt.Sprintf("%T", obj)}
+		}
+
+		return m.Mutate(ctx, ar, tObj)
+	})
+}
+
+// TypedWebhookConfig is the typed mutating webhook configuration.
+type TypedWebhookConfig[T metav1.Object] struct {
+	// ID is the id of the webhook.
+	ID string
+	// Mutator is the webhook typed mutator.
+	Mutator TypedMutator[T]
+	// Logger is the app logger.
+	Logger log.Logger
+	// PatchPathsAllow is the same as `WebhookConfig.PatchPathsAllow`.
+	PatchPathsAllow []string
+	// PatchPathsDeny is the same as `WebhookConfig.PatchPathsDeny`.
+	PatchPathsDeny []string
+	// PatchPathViolationPolicy is the same as `WebhookConfig.PatchPathViolationPolicy`.
+	PatchPathViolationPolicy PatchPathViolationPolicy
+	// IdempotencyCheckPolicy is the same as `WebhookConfig.IdempotencyCheckPolicy`.
+	IdempotencyCheckPolicy IdempotencyCheckPolicy
+	// MetricsRecorder is the same as `WebhookConfig.MetricsRecorder`.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+// NewTypedWebhook returns a mutating webhook for a single type of resource, the type must be a
+// pointer to a Kubernetes object type (e.g: `*corev1.Pod`). It has the same behavior as the regular
+// webhook, but the mutator will receive the object already typed.
+//
+// If the webhook receives a different kind of resource, the review will fail with a
+// `webhook.InvalidObjectTypeError`.
+func NewTypedWebhook[T metav1.Object](cfg TypedWebhookConfig[T]) (webhook.Webhook, error) {
+	obj, err := helpers.NewTypedObject[T]()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if cfg.Mutator == nil {
+		return nil, fmt.Errorf("invalid configuration: mutator is required")
+	}
+
+	mutator := FromTyped(cfg.Mutator)
+	return NewWebhook(WebhookConfig{
+		ID:                       cfg.ID,
+		Obj:                      obj,
+		Logger:                   cfg.Logger,
+		PatchPathsAllow:          cfg.PatchPathsAllow,
+		PatchPathsDeny:           cfg.PatchPathsDeny,
+		PatchPathViolationPolicy: cfg.PatchPathViolationPolicy,
+		IdempotencyCheckPolicy:   cfg.IdempotencyCheckPolicy,
+		MetricsRecorder:          cfg.MetricsRecorder,
+		Mutator: MutatorFunc(func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*MutatorResult, error) {
+			if err := helpers.CheckObjectKind(obj, ar.GVK); err != nil {
+				return nil, err
+			}
+
+			return mutator.Mutate(ctx, ar, obj)
+		}),
+	})
+}
@@ -0,0 +1,155 @@
+package mutating_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+)
+
+func getDeploymentJSON() []byte {
+	bs, _ := json.Marshal(&appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "testDeployment", Namespace: "testNS"},
+	})
+	return bs
+}
+
+func getTypedNSMutator(ns string) mutating.TypedMutator[*corev1.Pod] {
+	return mutating.TypedMutatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*mutating.MutatorResult, error) {
+		pod.Namespace = ns
+		return &mutating.MutatorResult{}, nil
+	})
+}
+
+func TestTypedWebhook(t *testing.T) {
+	tests := map[string]struct {
+		mutator     mutating.TypedMutator[*corev1.Pod]
+		review      model.AdmissionReview
+		expPatch    []string
+		expTypedErr bool
+		expErr      bool
+	}{
+		"A typed webhook should give the typed object to the mutator.": {
+			mutator: getTypedNSMutator("myChangedNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				GVK:          &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				NewObjectRaw: getPodJSON(),
+			},
+			expPatch: []string{`{"op":"replace","path":"/metadata/namespace","value":"myChangedNS"}`},
+		},
+
+		"A typed webhook should give the typed old object on updates.": {
+			mutator: mutating.TypedMutatorFunc[*corev1.Pod](func(ctx context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*mutating.MutatorResult, error) {
+				oldPod, ok := webhook.TypedOldObjectFromCtx[*corev1.Pod](ctx)
+				if !ok {
+					return nil, assert.AnError
+				}
+				pod.Namespace = oldPod.Name
+				return &mutating.MutatorResult{}, nil
+			}),
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationUpdate,
+				NewObjectRaw: getPodJSON(),
+				OldObjectRaw: getPodJSON(),
+			},
+			expPatch: []string{`{"op":"replace","path":"/metadata/namespace","value":"testPod"}`},
+		},
+
+		"A typed webhook should support typed chains.": {
+			mutator: mutating.NewTypedChain[*corev1.Pod](log.Noop,
+				getTypedNSMutator("ns1"),
+				mutating.TypedMutatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*mutating.MutatorResult, error) {
+					pod.Namespace += "-ns2"
+					return &mutating.MutatorResult{}, nil
+				}),
+			),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getPodJSON(),
+			},
+			expPatch: []string{`{"op":"replace","path":"/metadata/namespace","value":"ns1-ns2"}`},
+		},
+
+		"A typed webhook that receives a wrong kind should fail with a typed error.": {
+			mutator: getTypedNSMutator("myChangedNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				GVK:          &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+				NewObjectRaw: getDeploymentJSON(),
+			},
+			expErr:      true,
+			expTypedErr: true,
+		},
+
+		"A typed webhook that receives a wrong kind object without admission review kind should fail with a typed error.": {
+			mutator: getTypedNSMutator("myChangedNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getDeploymentJSON(),
+			},
+			expErr:      true,
+			expTypedErr: true,
+		},
+
+		"A typed chain with a mutator that returns a different type should fail with a typed error.": {
+			mutator: mutating.NewTypedChain[*corev1.Pod](log.Noop,
+				mutating.TypedMutatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*mutating.MutatorResult, error) {
+					return &mutating.MutatorResult{MutatedObject: &appsv1.Deployment{}}, nil
+				}),
+				getTypedNSMutator("ns1"),
+			),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getPodJSON(),
+			},
+			expErr:      true,
+			expTypedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			wh, err := mutating.NewTypedWebhook(mutating.TypedWebhookConfig[*corev1.Pod]{ID: "test", Mutator: test.mutator, PatchPathsDeny: []string{}})
+			require.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), test.review)
+
+			if test.expErr {
+				assert.Error(err)
+				var typedErr *webhook.InvalidObjectTypeError
+				assert.Equal(test.expTypedErr, errors.As(err, &typedErr))
+			} else if assert.NoError(err) {
+				got := gotResponse.(*model.MutatingAdmissionResponse)
+				gotPatch := string(got.JSONPatchPatch)
+				for _, expPatchOp := range test.expPatch {
+					assert.Contains(gotPatch, expPatchOp)
+				}
+			}
+		})
+	}
+}
+
+func TestTypedWebhookInvalidConfig(t *testing.T) {
+	_, err := mutating.NewTypedWebhook(mutating.TypedWebhookConfig[metav1.Object]{ID: "test", Mutator: mutating.TypedMutatorFunc[metav1.Object](nil)})
+	assert.Error(t, err)
+
+	_, err = mutating.NewTypedWebhook(mutating.TypedWebhookConfig[*corev1.Pod]{ID: "test"})
+	assert.Error(t, err)
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
 
 	"gomodules.xyz/jsonpatch/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +29,22 @@ type WebhookConfig struct {
 	Mutator Mutator
 	// Logger is the app logger.
 	Logger log.Logger
+	// PatchPathsAllow are the JSON patch paths (JSON pointers, e.g: `/metadata/labels`) that the mutations can change,
+	// including their children. By default all the paths are allowed.
+	PatchPathsAllow []string
+	// PatchPathsDeny are the JSON patch paths (JSON pointers, e.g: `/status`) that the mutations can't change,
+	// including their parents and children, except the ones of the requested subresource. By default
+	// `DefaultPatchPathsDeny`, use an empty list to allow all.
+	PatchPathsDeny []string
+	// PatchPathViolationPolicy is the policy applied when a mutation changes a not allowed path. By default
+	// the review will fail.
+	PatchPathViolationPolicy PatchPathViolationPolicy
+	// IdempotencyCheckPolicy enables the mutation idempotency check, that executes the mutator again using the
+	// mutated object and checks that it's not changed again. Mutators are executed twice, use it to catch not
+	// idempotent mutators (e.g on staging environments). By default disabled.
+	IdempotencyCheckPolicy IdempotencyCheckPolicy
+	// MetricsRecorder is used to measure the mutation idempotency checks. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
 }
 
 func (c *WebhookConfig) defaults() error {
@@ -41,15 +61,37 @@ func (c *WebhookConfig) defaults() error {
 	}
 	c.Logger = c.Logger.WithValues(log.Kv{"webhook-id": c.ID, "webhook-type": "mutating"})
 
+	if c.PatchPathsDeny == nil {
+		c.PatchPathsDeny = DefaultPatchPathsDeny
+	}
+
+	if c.PatchPathViolationPolicy == "" {
+		c.PatchPathViolationPolicy = PatchPathViolationPolicyFail
+	}
+
+	switch c.IdempotencyCheckPolicy {
+	case "":
+		c.IdempotencyCheckPolicy = IdempotencyCheckPolicyDisabled
+	case IdempotencyCheckPolicyDisabled, IdempotencyCheckPolicyWarn, IdempotencyCheckPolicyFail:
+	default:
+		return fmt.Errorf("unknown idempotency check policy %q", c.IdempotencyCheckPolicy)
+	}
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
 	return nil
 }
 
 type mutatingWebhook struct {
-	id            string
-	objectCreator helpers.ObjectCreator
-	mutator       Mutator
-	cfg           WebhookConfig
-	logger        log.Logger
+	id              string
+	objectCreator   helpers.ObjectCreator
+	mutator         Mutator
+	pathGuard       *patchPathGuard
+	metricsRecorder webhook.MutationIdempotencyMetricsRecorder
+	cfg             WebhookConfig
+	logger          log.Logger
 }
 
 // NewWebhook is a mutating webhook and will return a webhook ready for a type of resource.
@@ -69,12 +111,19 @@ func NewWebhook(cfg WebhookConfig) (webhook.Webhook, error) {
 		oc = helpers.NewDynamicObjectCreator()
 	}
 
+	pathGuard, err := newPatchPathGuard(cfg.PatchPathsAllow, cfg.PatchPathsDeny, cfg.PatchPathViolationPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	return &mutatingWebhook{
-		objectCreator: oc,
-		id:            cfg.ID,
-		mutator:       cfg.Mutator,
-		cfg:           cfg,
-		logger:        cfg.Logger,
+		objectCreator:   oc,
+		id:              cfg.ID,
+		mutator:         cfg.Mutator,
+		pathGuard:       pathGuard,
+		metricsRecorder: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+		cfg:             cfg,
+		logger:          cfg.Logger,
 	}, nil
 }
 
@@ -82,7 +131,16 @@ func (w mutatingWebhook) ID() string { return w.id }
 
 func (w mutatingWebhook) Kind() model.WebhookKind { return model.WebhookKindMutating }
 
-func (w mutatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (model.AdmissionResponse, error) {
+func (w mutatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (resp model.AdmissionResponse, err error) {
+	// Don't let the user mutators break the webhook with panics, handle them as an error.
+	defer func() {
+		if p := recover(); p != nil {
+			w.logger.WithCtxValues(ctx).Errorf("Mutator panic recovered: %v\n%s", p, debug.Stack())
+			resp = nil
+			err = fmt.Errorf("mutator panic: %v", p)
+		}
+	}()
+
 	// Delete operations don't have body because should be gone on the deletion, instead they have the body
 	// of the object we want to delete as an old object.
 	raw := ar.NewObjectRaw
@@ -101,6 +159,15 @@ func (w mutatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (
 		return nil, fmt.Errorf("impossible to type assert the deep copy to metav1.Object")
 	}
 
+	// On updates, give the decoded old object to the users so they can check the transitions.
+	if ar.Operation == model.OperationUpdate && len(ar.OldObjectRaw) > 0 {
+		oldObj, err := w.objectCreator.NewObject(ar.OldObjectRaw)
+		if err != nil {
+			return nil, fmt.Errorf("could not create old object from raw: %w", err)
+		}
+		ctx = webhook.CtxWithOldObject(ctx, oldObj)
+	}
+
 	res, err := w.mutatingAdmissionReview(ctx, ar, raw, mutatingObj)
 	if err != nil {
 		return nil, err
@@ -112,6 +179,14 @@ func (w mutatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (
 }
 
 func (w mutatingWebhook) mutatingAdmissionReview(ctx context.Context, ar model.AdmissionReview, rawObj []byte, objForMutation metav1.Object) (*model.MutatingAdmissionResponse, error) {
+	// Get the object as it would be marshaled without mutations, so we can ignore the
+	// differences with the raw object that are only caused by the serialization of the types
+	// (e.g: `creationTimestamp: null`, empty `status`...).
+	unmutatedJSON, err := json.Marshal(objForMutation)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal into JSON the object: %w", err)
+	}
+
 	// Mutate the object.
 	res, err := w.mutator.Mutate(ctx, &ar, objForMutation)
 	if err != nil {
@@ -122,22 +197,62 @@ func (w mutatingWebhook) mutatingAdmissionReview(ctx context.Context, ar model.A
 		return nil, fmt.Errorf("result is required, mutator result is nil")
 	}
 
+	// Denied requests are not mutated.
+	if res.Denied {
+		w.logger.WithCtxValues(ctx).Debugf("Webhook mutating review denied: %s", res.Message)
+		return &model.MutatingAdmissionResponse{
+			ID:               ar.ID,
+			Warnings:         res.Warnings,
+			AuditAnnotations: res.AuditAnnotations,
+			Denied:           true,
+			Message:          res.Message,
+			Code:             res.Code,
+			Reason:           res.Reason,
+		}, nil
+	}
+
 	// If the user returned a mutated object, it will not be used the one we provided to the mutator.
-	// if nil then, we use the one we provided.
-	mutatedObj := objForMutation
-	if res.MutatedObject != nil {
-		mutatedObj = res.MutatedObject
+	// if nil then, we use the one we provided. The returned patches are applied to it.
+	mutatedObj, err := resultMutatedObject(objForMutation, res)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mutator result: %w", err)
 	}
 	mutatedJSON, err := json.Marshal(mutatedObj)
 	if err != nil {
 		return nil, fmt.Errorf("could not marshal into JSON mutated object: %w", err)
 	}
 
+	if w.cfg.IdempotencyCheckPolicy != IdempotencyCheckPolicyDisabled {
+		err := w.checkIdempotency(ctx, ar, mutatedJSON)
+		if err != nil {
+			return nil, fmt.Errorf("idempotency check failed: %w", err)
+		}
+	}
+
 	patch, err := jsonpatch.CreatePatch(rawObj, mutatedJSON)
 	if err != nil {
 		return nil, fmt.Errorf("could not create JSON patch: %w", err)
 	}
 
+	serializationPatch, err := jsonpatch.CreatePatch(rawObj, unmutatedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("could not create serialization JSON patch: %w", err)
+	}
+	mutationPatch, err := jsonpatch.CreatePatch(unmutatedJSON, mutatedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("could not create mutation JSON patch: %w", err)
+	}
+	patch = removePatchOperations(patch, untouchedPatchOperations(serializationPatch, mutationPatch))
+
+	// Don't allow changing protected paths.
+	patch, guardWarnings, err := w.pathGuard.guard(ar, patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mutation: %w", err)
+	}
+	for _, gw := range guardWarnings {
+		w.logger.WithCtxValues(ctx).Warningf("Not allowed patch operation removed: %s", gw)
+	}
+
 	marshalledPatch, err := json.Marshal(patch)
 	if err != nil {
 		return nil, fmt.Errorf("could not mashal into JSON, the JSON patch: %w", err)
@@ -145,8 +260,86 @@ func (w mutatingWebhook) mutatingAdmissionReview(ctx context.Context, ar model.A
 
 	// Forge response.
 	return &model.MutatingAdmissionResponse{
-		ID:             ar.ID,
-		JSONPatchPatch: marshalledPatch,
-		Warnings:       res.Warnings,
+		ID:               ar.ID,
+		JSONPatchPatch:   marshalledPatch,
+		Warnings:         append(res.Warnings, guardWarnings...),
+		AuditAnnotations: res.AuditAnnotations,
+		MutatorPatches:   res.MutatorPatches,
 	}, nil
 }
+
+// untouchedPatchOperations returns the patch operations whose paths are not changed by the mutation
+// patch operations. The operations inside array elements changed by the mutation, or shifted by added
+// or removed elements on the same array, are not returned (e.g: `/spec/containers/0/newField` when a
+// container is prepended), the same path refers to a different element after the mutation.
+func untouchedPatchOperations(ops, mutation []jsonpatch.Operation) []jsonpatch.Operation {
+	res := make([]jsonpatch.Operation, 0, len(ops))
+	for _, op := range ops {
+		if !mutationTouchesPath(op.Path, mutation) {
+			res = append(res, op)
+		}
+	}
+
+	return res
+}
+
+func mutationTouchesPath(path string, mutation []jsonpatch.Operation) bool {
+	for _, mop := range mutation {
+		if pathsOverlap(mop.Path, path) {
+			return true
+		}
+	}
+
+	// Check the array elements of the path (e.g: `/spec/containers/0`).
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		index, err := strconv.Atoi(segments[i])
+		if err != nil {
+			continue
+		}
+
+		element := strings.Join(segments[:i+1], "/")
+		array := strings.Join(segments[:i], "/")
+		for _, mop := range mutation {
+			if pathsOverlap(mop.Path, element) {
+				return true
+			}
+
+			// Added or removed elements shift the next elements of the array.
+			if mop.Operation != "add" && mop.Operation != "remove" {
+				continue
+			}
+			mopIndex, err := strconv.Atoi(strings.TrimPrefix(mop.Path, array+"/"))
+			if err == nil && strings.HasPrefix(mop.Path, array+"/") && mopIndex <= index {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// removePatchOperations returns the patch without the operations that are on the
+// operations to remove.
+func removePatchOperations(patch, remove []jsonpatch.Operation) []jsonpatch.Operation {
+	if len(remove) == 0 {
+		return patch
+	}
+
+	res := make([]jsonpatch.Operation, 0, len(patch))
+	for _, op := range patch {
+		found := false
+		for _, rop := range remove {
+			if op.Operation == rop.Operation && op.Path == rop.Path && reflect.DeepEqual(op.Value, rop.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			res = append(res, op)
+		}
+	}
+
+	return res
+}
@@ -7,13 +7,19 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	jsonpatchapply "gopkg.in/evanphx/json-patch.v4"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
 )
 
 func getPodJSON() []byte {
@@ -136,8 +142,20 @@ func TestPodAdmissionReviewMutation(t *testing.T) {
 			expErr: true,
 		},
 
-		"A static webhook review of a Pod with an ns mutator should mutate the ns.": {
+		"A webhook review with a mutator panic should return an error.": {
 			cfg: mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			mutator: mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+				panic("wanted panic")
+			}),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getPodJSON(),
+			},
+			expErr: true,
+		},
+
+		"A static webhook review of a Pod with an ns mutator should mutate the ns.": {
+			cfg: mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathsDeny: []string{}},
 			mutator: mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
 				pod, ok := obj.(*corev1.Pod)
 				if !ok {
@@ -159,7 +177,7 @@ func TestPodAdmissionReviewMutation(t *testing.T) {
 		},
 
 		"Mutators that return nil as mutated object should get the original received object to get the patch.": {
-			cfg:     mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			cfg:     mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathsDeny: []string{}},
 			mutator: getPodNSMutator("myChangedNS"),
 			review: model.AdmissionReview{
 				ID:           "test",
@@ -217,7 +235,7 @@ func TestPodAdmissionReviewMutation(t *testing.T) {
 		},
 
 		"A dynamic webhook review of a Pod with an ns mutator should mutate the ns.": {
-			cfg:     mutating.WebhookConfig{ID: "test"},
+			cfg:     mutating.WebhookConfig{ID: "test", PatchPathsDeny: []string{}},
 			mutator: getPodNSMutator("myChangedNS"),
 			review: model.AdmissionReview{
 				ID:           "test",
@@ -382,3 +400,591 @@ func TestPodAdmissionReviewMutation(t *testing.T) {
 		})
 	}
 }
+
+func TestPodAdmissionReviewMutationDenial(t *testing.T) {
+	tests := map[string]struct {
+		mutator     mutating.Mutator
+		expResponse model.AdmissionResponse
+	}{
+		"A mutator that denies the request should return a denied response without patch.": {
+			mutator: mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+				obj.SetNamespace("myChangedNS")
+				return &mutating.MutatorResult{
+					Denied:   true,
+					Message:  "can't be fixed",
+					Code:     403,
+					Reason:   metav1.StatusReasonForbidden,
+					Warnings: []string{"w1"},
+				}, nil
+			}),
+			expResponse: &model.MutatingAdmissionResponse{
+				ID:       "test",
+				Denied:   true,
+				Message:  "can't be fixed",
+				Code:     403,
+				Reason:   metav1.StatusReasonForbidden,
+				Warnings: []string{"w1"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			wh, err := mutating.NewWebhook(mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, Mutator: test.mutator})
+			assert.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()})
+			if assert.NoError(err) {
+				assert.Equal(test.expResponse, gotResponse)
+			}
+		})
+	}
+}
+
+func TestPodAdmissionReviewMutationOldObject(t *testing.T) {
+	tests := map[string]struct {
+		cfg      mutating.WebhookConfig
+		review   model.AdmissionReview
+		expPatch []string
+		expErr   bool
+	}{
+		"A static webhook review of an update operation should give the decoded old object.": {
+			cfg: mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationUpdate,
+				NewObjectRaw: getPodJSON(),
+				OldObjectRaw: getPodJSON(),
+			},
+			expPatch: []string{
+				`{"op":"add","path":"/metadata/annotations/old-name","value":"testPod"}`,
+			},
+		},
+
+		"A dynamic webhook review of an update operation should give the decoded old object.": {
+			cfg: mutating.WebhookConfig{ID: "test"},
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationUpdate,
+				NewObjectRaw: getPodJSON(),
+				OldObjectRaw: getPodJSON(),
+			},
+			expPatch: []string{
+				`{"op":"add","path":"/metadata/annotations/old-name","value":"testPod"}`,
+			},
+		},
+
+		"A webhook review of an update operation with an invalid old object should fail.": {
+			cfg: mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationUpdate,
+				NewObjectRaw: getPodJSON(),
+				OldObjectRaw: []byte(`{`),
+			},
+			expErr: true,
+		},
+
+		"A webhook review of a create operation shouldn't give the old object.": {
+			cfg: mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationCreate,
+				NewObjectRaw: getPodJSON(),
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			test.cfg.Mutator = mutating.MutatorFunc(func(ctx context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+				oldObj, ok := webhook.OldObjectFromCtx(ctx)
+				if !ok {
+					return nil, fmt.Errorf("missing old object")
+				}
+				annotations := obj.GetAnnotations()
+				annotations["old-name"] = oldObj.GetName()
+				obj.SetAnnotations(annotations)
+				return &mutating.MutatorResult{}, nil
+			})
+			wh, err := mutating.NewWebhook(test.cfg)
+			assert.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), test.review)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				got := gotResponse.(*model.MutatingAdmissionResponse)
+				gotPatch := string(got.JSONPatchPatch)
+				for _, expPatchOp := range test.expPatch {
+					assert.Contains(gotPatch, expPatchOp)
+				}
+			}
+		})
+	}
+}
+
+func TestPodAdmissionReviewMutationPatchPathGuard(t *testing.T) {
+	// Mutates the namespace, the status and a label.
+	mutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		pod.Namespace = "myChangedNS"
+		pod.Labels = map[string]string{"app": "test"}
+		pod.Status.Phase = corev1.PodRunning
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+
+	tests := map[string]struct {
+		cfg         mutating.WebhookConfig
+		subResource string
+		expPatch    string
+		expWarnings []string
+		expErr      bool
+		expCfgErr   bool
+	}{
+		"By default, changing protected paths should fail the review.": {
+			cfg:    mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			expErr: true,
+		},
+
+		"Without deny list, all the paths should be allowed.": {
+			cfg:      mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathsDeny: []string{}},
+			expPatch: `[{"op":"replace","path":"/metadata/namespace","value":"myChangedNS"},{"op":"add","path":"/metadata/labels","value":{"app":"test"}},{"op":"add","path":"/status/phase","value":"Running"}]`,
+		},
+
+		"Changing protected paths with strip policy should remove the operations and warn.": {
+			cfg:      mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathViolationPolicy: mutating.PatchPathViolationPolicyStrip},
+			expPatch: `[{"op":"add","path":"/metadata/labels","value":{"app":"test"}}]`,
+			expWarnings: []string{
+				`mutation "replace" operation on "/metadata/namespace" path is not allowed, ignored`,
+				`mutation "add" operation on "/status/phase" path is not allowed, ignored`,
+			},
+		},
+
+		"Changing the status on status subresource requests should be allowed.": {
+			cfg:         mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathViolationPolicy: mutating.PatchPathViolationPolicyStrip},
+			subResource: "status",
+			expPatch:    `[{"op":"add","path":"/metadata/labels","value":{"app":"test"}},{"op":"add","path":"/status/phase","value":"Running"}]`,
+			expWarnings: []string{
+				`mutation "replace" operation on "/metadata/namespace" path is not allowed, ignored`,
+			},
+		},
+
+		"Changing paths outside the allow list with strip policy should remove the operations and warn.": {
+			cfg: mutating.WebhookConfig{
+				ID:                       "test",
+				Obj:                      &corev1.Pod{},
+				PatchPathsAllow:          []string{"/metadata/labels", "/metadata/namespace"},
+				PatchPathsDeny:           []string{},
+				PatchPathViolationPolicy: mutating.PatchPathViolationPolicyStrip,
+			},
+			expPatch: `[{"op":"replace","path":"/metadata/namespace","value":"myChangedNS"},{"op":"add","path":"/metadata/labels","value":{"app":"test"}}]`,
+			expWarnings: []string{
+				`mutation "add" operation on "/status/phase" path is not allowed, ignored`,
+			},
+		},
+
+		"Denied paths should have priority over the allowed ones.": {
+			cfg: mutating.WebhookConfig{
+				ID:                       "test",
+				Obj:                      &corev1.Pod{},
+				PatchPathsAllow:          []string{"/metadata"},
+				PatchPathViolationPolicy: mutating.PatchPathViolationPolicyStrip,
+			},
+			expPatch: `[{"op":"add","path":"/metadata/labels","value":{"app":"test"}}]`,
+			expWarnings: []string{
+				`mutation "replace" operation on "/metadata/namespace" path is not allowed, ignored`,
+				`mutation "add" operation on "/status/phase" path is not allowed, ignored`,
+			},
+		},
+
+		"Changing paths outside the allow list should fail the review.": {
+			cfg:    mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathsAllow: []string{"/metadata/labels"}, PatchPathsDeny: []string{}},
+			expErr: true,
+		},
+
+		"Invalid paths should fail the configuration.": {
+			cfg:       mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathsDeny: []string{"status"}},
+			expCfgErr: true,
+		},
+
+		"Invalid policies should fail the configuration.": {
+			cfg:       mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, PatchPathViolationPolicy: "unknown"},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			test.cfg.Mutator = mutator
+			wh, err := mutating.NewWebhook(test.cfg)
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{ID: "test", SubResource: test.subResource, NewObjectRaw: getPodJSON()})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				// JSON patch operations order is not deterministic.
+				got := gotResponse.(*model.MutatingAdmissionResponse)
+				var expPatch, gotPatch []map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(test.expPatch), &expPatch))
+				require.NoError(t, json.Unmarshal(got.JSONPatchPatch, &gotPatch))
+				assert.ElementsMatch(expPatch, gotPatch)
+				assert.ElementsMatch(test.expWarnings, got.Warnings)
+			}
+		})
+	}
+}
+
+func TestAdmissionReviewMutationNoopPatch(t *testing.T) {
+	noopMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+		return &mutating.MutatorResult{}, nil
+	})
+	labelMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		obj.SetLabels(map[string]string{"app": "test"})
+		return &mutating.MutatorResult{}, nil
+	})
+
+	tests := map[string]struct {
+		obj      metav1.Object
+		raw      string
+		mutator  mutating.Mutator
+		expPatch string
+	}{
+		"A no-op mutation on a static pod should not have patch.": {
+			obj:      &corev1.Pod{},
+			raw:      `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test","namespace":"test"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A no-op mutation on a dynamic pod should not have patch.": {
+			raw:      `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test","namespace":"test"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A no-op mutation on a static deployment should not have patch.": {
+			obj:      &appsv1.Deployment{},
+			raw:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test","namespace":"test"},"spec":{"selector":{"matchLabels":{"app":"test"}},"template":{"metadata":{"labels":{"app":"test"}},"spec":{"containers":[{"name":"app","image":"nginx"}]}}}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A no-op mutation on a static service should not have patch.": {
+			obj:      &corev1.Service{},
+			raw:      `{"apiVersion":"v1","kind":"Service","metadata":{"name":"test","namespace":"test"},"spec":{"ports":[{"port":80}],"selector":{"app":"test"}}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A no-op mutation on a static configmap should not have patch.": {
+			obj:      &corev1.ConfigMap{},
+			raw:      `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test"},"data":{"k":"v"}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A no-op mutation on a static secret should not have patch.": {
+			obj:      &corev1.Secret{},
+			raw:      `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test"},"type":"Opaque","data":{"k":"dg=="}}`,
+			mutator:  noopMutator,
+			expPatch: `[]`,
+		},
+
+		"A mutation on a static deployment should only have the mutation patch.": {
+			obj:      &appsv1.Deployment{},
+			raw:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test","namespace":"test"},"spec":{"selector":{"matchLabels":{"app":"test"}},"template":{"metadata":{"labels":{"app":"test"}},"spec":{"containers":[{"name":"app","image":"nginx"}]}}}}`,
+			mutator:  labelMutator,
+			expPatch: `[{"op":"add","path":"/metadata/labels","value":{"app":"test"}}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			wh, err := mutating.NewWebhook(mutating.WebhookConfig{ID: "test", Obj: test.obj, Mutator: test.mutator})
+			require.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{ID: "test", Operation: model.OperationCreate, NewObjectRaw: []byte(test.raw)})
+			require.NoError(err)
+
+			got := gotResponse.(*model.MutatingAdmissionResponse)
+			assert.JSONEq(test.expPatch, string(got.JSONPatchPatch))
+		})
+	}
+}
+
+func TestAdmissionReviewMutationSerializationPatch(t *testing.T) {
+	sidecar := corev1.Container{Name: "sidecar", Image: "sidecar"}
+	prependContainerMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		pod.Spec.Containers = append([]corev1.Container{sidecar}, pod.Spec.Containers...)
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+	prependInitContainerMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		pod.Spec.InitContainers = append([]corev1.Container{sidecar}, pod.Spec.InitContainers...)
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+	appendContainerMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		pod.Spec.Containers = append(pod.Spec.Containers, sidecar)
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+
+	tests := map[string]struct {
+		raw        string
+		mutator    mutating.Mutator
+		expPatched string
+	}{
+		"Prepending a container should not move the unknown fields of the shifted containers to the new one.": {
+			raw:        `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"app","image":"nginx","newField":"x"}]}}`,
+			mutator:    prependContainerMutator,
+			expPatched: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"sidecar","image":"sidecar","resources":{}},{"name":"app","image":"nginx","resources":{}}]}}`,
+		},
+
+		"Prepending an init container should not move the unknown fields of the shifted init containers to the new one.": {
+			raw:        `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"initContainers":[{"name":"init","image":"busybox","newField":"x"}],"containers":[{"name":"app","image":"nginx","newField":"x"}]}}`,
+			mutator:    prependInitContainerMutator,
+			expPatched: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"initContainers":[{"name":"sidecar","image":"sidecar","resources":{}},{"name":"init","image":"busybox","resources":{}}],"containers":[{"name":"app","image":"nginx","newField":"x"}]}}`,
+		},
+
+		"Appending a container should keep the unknown fields of the not shifted containers.": {
+			raw:        `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"app","image":"nginx","newField":"x"}]}}`,
+			mutator:    appendContainerMutator,
+			expPatched: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test"},"spec":{"containers":[{"name":"app","image":"nginx","newField":"x"},{"name":"sidecar","image":"sidecar","resources":{}}]}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			wh, err := mutating.NewWebhook(mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}, Mutator: test.mutator})
+			require.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{ID: "test", Operation: model.OperationCreate, NewObjectRaw: []byte(test.raw)})
+			require.NoError(err)
+
+			got := gotResponse.(*model.MutatingAdmissionResponse)
+			patch, err := jsonpatchapply.DecodePatch(got.JSONPatchPatch)
+			require.NoError(err)
+			gotPatched, err := patch.Apply([]byte(test.raw))
+			require.NoError(err)
+			assert.JSONEq(test.expPatched, string(gotPatched))
+		})
+	}
+}
+
+func TestAdmissionReviewMutationResultPatches(t *testing.T) {
+	tests := map[string]struct {
+		cfg      mutating.WebhookConfig
+		raw      []byte
+		result   mutating.MutatorResult
+		expPatch string
+		expErr   bool
+	}{
+		"A JSON patch result should be returned as the JSON patch.": {
+			cfg:      mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			result:   mutating.MutatorResult{JSONPatch: []byte(`[{"op":"add","path":"/spec/containers/-","value":{"name":"container3","resources":{}}}]`)},
+			expPatch: `[{"op":"add","path":"/spec/containers/2","value":{"name":"container3","resources":{}}}]`,
+		},
+
+		"A JSON merge patch result should be converted to a JSON patch.": {
+			cfg:      mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			result:   mutating.MutatorResult{MergePatch: []byte(`{"metadata":{"annotations":{"key1":null,"key5":"val5"}}}`)},
+			expPatch: `[{"op":"remove","path":"/metadata/annotations/key1"},{"op":"add","path":"/metadata/annotations/key5","value":"val5"}]`,
+		},
+
+		"A strategic merge patch result should be converted to a JSON patch.": {
+			cfg:      mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			result:   mutating.MutatorResult{StrategicMergePatch: []byte(`{"spec":{"containers":[{"name":"container2","image":"nginx"}]}}`)},
+			expPatch: `[{"op":"add","path":"/spec/containers/1/image","value":"nginx"}]`,
+		},
+
+		"A JSON merge patch result on an unstructured object should be converted to a JSON patch.": {
+			cfg:      mutating.WebhookConfig{ID: "test"},
+			raw:      []byte(`{"apiVersion":"example.com/v1","kind":"Test","metadata":{"name":"test"},"spec":{"replicas":1}}`),
+			result:   mutating.MutatorResult{MergePatch: []byte(`{"spec":{"replicas":2}}`)},
+			expPatch: `[{"op":"replace","path":"/spec/replicas","value":2}]`,
+		},
+
+		"A strategic merge patch result on an unstructured object should fail.": {
+			cfg:    mutating.WebhookConfig{ID: "test"},
+			raw:    []byte(`{"apiVersion":"example.com/v1","kind":"Test","metadata":{"name":"test"},"spec":{"replicas":1}}`),
+			result: mutating.MutatorResult{StrategicMergePatch: []byte(`{"spec":{"replicas":2}}`)},
+			expErr: true,
+		},
+
+		"An invalid JSON patch result should fail.": {
+			cfg:    mutating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			result: mutating.MutatorResult{JSONPatch: []byte(`{"op":"add"}`)},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			test.cfg.Mutator = mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*mutating.MutatorResult, error) {
+				res := test.result
+				return &res, nil
+			})
+			wh, err := mutating.NewWebhook(test.cfg)
+			require.NoError(err)
+
+			raw := test.raw
+			if raw == nil {
+				raw = getPodJSON()
+			}
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{ID: "test", NewObjectRaw: raw})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				// JSON patch operations order is not deterministic.
+				got := gotResponse.(*model.MutatingAdmissionResponse)
+				var expPatch, gotPatch []map[string]interface{}
+				require.NoError(json.Unmarshal([]byte(test.expPatch), &expPatch))
+				require.NoError(json.Unmarshal(got.JSONPatchPatch, &gotPatch))
+				assert.ElementsMatch(expPatch, gotPatch)
+			}
+		})
+	}
+}
+
+func TestPodAdmissionReviewMutationIdempotencyCheck(t *testing.T) {
+	// Appends a container on every mutation.
+	notIdempotentMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar"})
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+
+	// Adds the container only if missing.
+	idempotentMutator := mutating.MutatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		pod := obj.(*corev1.Pod)
+		for _, c := range pod.Spec.Containers {
+			if c.Name == "sidecar" {
+				return &mutating.MutatorResult{}, nil
+			}
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar"})
+		return &mutating.MutatorResult{MutatedObject: pod}, nil
+	})
+
+	tests := map[string]struct {
+		cfg       mutating.WebhookConfig
+		mock      func(m *webhookmock.FullMetricsRecorder)
+		expPatch  []string
+		expErr    bool
+		expCfgErr bool
+	}{
+		"Without idempotency check, not idempotent mutators should mutate.": {
+			cfg:      mutating.WebhookConfig{Mutator: notIdempotentMutator},
+			mock:     func(m *webhookmock.FullMetricsRecorder) {},
+			expPatch: []string{`{"op":"add","path":"/spec/containers/2","value":{"name":"sidecar","resources":{}}}`},
+		},
+
+		"With idempotency check, idempotent mutators should be measured and mutate.": {
+			cfg: mutating.WebhookConfig{Mutator: idempotentMutator, IdempotencyCheckPolicy: mutating.IdempotencyCheckPolicyFail},
+			mock: func(m *webhookmock.FullMetricsRecorder) {
+				m.On("MeasureMutationIdempotencyOp", mock.Anything, webhook.MeasureMutationIdempotencyOpData{
+					WebhookID:              "test",
+					AdmissionReviewVersion: "v1",
+					ResourceKind:           "v1/Pod",
+					Operation:              "create",
+					Idempotent:             true,
+				}).Once().Return()
+			},
+			expPatch: []string{`{"op":"add","path":"/spec/containers/2","value":{"name":"sidecar","resources":{}}}`},
+		},
+
+		"With idempotency warn check, not idempotent mutators should be measured and mutate.": {
+			cfg: mutating.WebhookConfig{Mutator: notIdempotentMutator, IdempotencyCheckPolicy: mutating.IdempotencyCheckPolicyWarn},
+			mock: func(m *webhookmock.FullMetricsRecorder) {
+				m.On("MeasureMutationIdempotencyOp", mock.Anything, webhook.MeasureMutationIdempotencyOpData{
+					WebhookID:              "test",
+					AdmissionReviewVersion: "v1",
+					ResourceKind:           "v1/Pod",
+					Operation:              "create",
+					Idempotent:             false,
+				}).Once().Return()
+			},
+			expPatch: []string{`{"op":"add","path":"/spec/containers/2","value":{"name":"sidecar","resources":{}}}`},
+		},
+
+		"With idempotency fail check, not idempotent mutators should be measured and fail.": {
+			cfg: mutating.WebhookConfig{Mutator: notIdempotentMutator, IdempotencyCheckPolicy: mutating.IdempotencyCheckPolicyFail},
+			mock: func(m *webhookmock.FullMetricsRecorder) {
+				m.On("MeasureMutationIdempotencyOp", mock.Anything, webhook.MeasureMutationIdempotencyOpData{
+					WebhookID:              "test",
+					AdmissionReviewVersion: "v1",
+					ResourceKind:           "v1/Pod",
+					Operation:              "create",
+					Idempotent:             false,
+				}).Once().Return()
+			},
+			expErr: true,
+		},
+
+		"An invalid idempotency check policy should fail the configuration.": {
+			cfg:       mutating.WebhookConfig{Mutator: idempotentMutator, IdempotencyCheckPolicy: "unknown"},
+			mock:      func(m *webhookmock.FullMetricsRecorder) {},
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			test.mock(mrec)
+
+			test.cfg.ID = "test"
+			test.cfg.Obj = &corev1.Pod{}
+			test.cfg.MetricsRecorder = mrec
+			wh, err := mutating.NewWebhook(test.cfg)
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), model.AdmissionReview{
+				ID:           "test",
+				Version:      model.AdmissionReviewVersionV1,
+				Operation:    model.OperationCreate,
+				RequestGVK:   &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				NewObjectRaw: getPodJSON(),
+			})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				got := gotResponse.(*model.MutatingAdmissionResponse)
+				gotPatch := string(got.JSONPatchPatch)
+				for _, expPatchOp := range test.expPatch {
+					assert.Contains(gotPatch, expPatchOp)
+				}
+			}
+		})
+	}
+}
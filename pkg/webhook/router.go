@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+)
+
+// RouteResource is a resource (and subresource) used to route the admission reviews
+// (e.g: `mutating.NewKindRouter`, `validating.NewKindRouter`).
+type RouteResource struct {
+	Group       string
+	Version     string
+	Resource    string
+	SubResource string
+}
+
+func (r RouteResource) String() string {
+	s := fmt.Sprintf("%s/%s/%s", r.Group, r.Version, r.Resource)
+	if r.SubResource != "" {
+		s += "/" + r.SubResource
+	}
+	return s
+}
+
+// RequestRouteResource returns the route resource of the admission review. It uses the resource
+// the object was converted to for the webhook (GVR), falling back to the resource of the original
+// request (RequestGVR) when not set.
+func RequestRouteResource(ar model.AdmissionReview) (RouteResource, bool) {
+	if ar.GVR != nil {
+		return RouteResource{
+			Group:       ar.GVR.Group,
+			Version:     ar.GVR.Version,
+			Resource:    ar.GVR.Resource,
+			SubResource: ar.SubResource,
+		}, true
+	}
+
+	if ar.RequestGVR != nil {
+		return RouteResource{
+			Group:       ar.RequestGVR.Group,
+			Version:     ar.RequestGVR.Version,
+			Resource:    ar.RequestGVR.Resource,
+			SubResource: ar.RequestSubResource,
+		}, true
+	}
+
+	return RouteResource{}, false
+}
+
+// RequestRouteKind returns the route kind of the admission review. It uses the kind the object
+// was converted to for the webhook (GVK), falling back to the kind of the original request
+// (RequestGVK) when not set.
+func RequestRouteKind(ar model.AdmissionReview) (metav1.GroupVersionKind, bool) {
+	if ar.GVK != nil {
+		return *ar.GVK, true
+	}
+
+	if ar.RequestGVK != nil {
+		return *ar.RequestGVK, true
+	}
+
+	return metav1.GroupVersionKind{}, false
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepTimeoutPolicy is the policy applied when a step (mutator or validator) of a webhook
+// times out (e.g: `mutating.NewTimeoutMutator`, `validating.NewTimeoutValidator`).
+type StepTimeoutPolicy string
+
+const (
+	// StepTimeoutPolicySkip will ignore the step, as if it didn't mutate or it was valid.
+	StepTimeoutPolicySkip StepTimeoutPolicy = "skip"
+	// StepTimeoutPolicyDeny will deny the request.
+	StepTimeoutPolicyDeny StepTimeoutPolicy = "deny"
+	// StepTimeoutPolicyError will fail the review with an error, the webhook failure policy will be applied.
+	StepTimeoutPolicyError StepTimeoutPolicy = "error"
+)
+
+// Validate validates the policy.
+func (p StepTimeoutPolicy) Validate() error {
+	switch p {
+	case StepTimeoutPolicySkip, StepTimeoutPolicyDeny, StepTimeoutPolicyError:
+		return nil
+	}
+
+	return fmt.Errorf("unknown step timeout policy %q", p)
+}
+
+// ErrStepTimeout is the error used when a step times out with the error policy, it can be
+// checked with `errors.Is`.
+var ErrStepTimeout = errors.New("step timed out")
+
+// NewStepTimeoutError returns the error used when a step times out with the error policy.
+//
+// It's not an admission error (`AdmissionError`), so the review will fail and the webhook failure
+// policy will be applied.
+func NewStepTimeoutError(step string, timeout time.Duration) error {
+	return fmt.Errorf("%q %w after %s", step, ErrStepTimeout, timeout)
+}
+
+// StepTimeoutMessage returns the message used when a step times out.
+func StepTimeoutMessage(step string, timeout time.Duration) string {
+	return fmt.Sprintf("%q %s after %s", step, ErrStepTimeout, timeout)
+}
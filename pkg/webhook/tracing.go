@@ -59,6 +59,7 @@ func (t tracedWebhook) Review(ctx context.Context, ar model.AdmissionReview) (re
 				"warnings":     r.Warnings,
 				"has_warnings": len(r.Warnings) > 0,
 				"mutated":      hasMutated(r),
+				"allowed":      !r.Denied,
 			})
 
 		default:
@@ -0,0 +1,96 @@
+package validating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// CanaryValidatorConfig is the configuration of a canary validator.
+type CanaryValidatorConfig struct {
+	// Name is the name of the canary rollout, used on the logs and metrics.
+	Name string
+	// Stable is the validator used by the not selected requests (e.g: the old implementation).
+	Stable Validator
+	// Canary is the validator used by the selected requests (e.g: the new implementation).
+	Canary Validator
+	// Selector selects the requests that will use the canary validator.
+	Selector webhook.CanarySelector
+	// Logger is the logger.
+	Logger log.Logger
+	// MetricsRecorder is used to measure the reviews of both variants. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+func (c *CanaryValidatorConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.Stable == nil {
+		return fmt.Errorf("stable validator is required")
+	}
+
+	if c.Canary == nil {
+		return fmt.Errorf("canary validator is required")
+	}
+
+	if err := c.Selector.Validate(); err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"canary": c.Name})
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+type canaryValidator struct {
+	cfg        CanaryValidatorConfig
+	metricsRec webhook.CanaryMetricsRecorder
+}
+
+// NewCanaryValidator returns a validator that will validate the requests selected by the canary selector using
+// the canary validator, and the rest using the stable validator.
+func NewCanaryValidator(cfg CanaryValidatorConfig) (Validator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return canaryValidator{
+		cfg:        cfg,
+		metricsRec: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+	}, nil
+}
+
+func (c canaryValidator) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (res *ValidatorResult, err error) {
+	variant, v := webhook.CanaryVariantStable, c.cfg.Stable
+	if c.cfg.Selector.Select(ar, obj) {
+		variant, v = webhook.CanaryVariantCanary, c.cfg.Canary
+	}
+
+	defer func() {
+		c.metricsRec.MeasureCanaryOp(ctx, webhook.MeasureCanaryOpData{
+			CanaryName:  c.cfg.Name,
+			WebhookType: model.WebhookKindValidating,
+			Variant:     variant,
+			Success:     err == nil && res != nil,
+			Allowed:     err == nil && res != nil && res.Valid,
+		})
+	}()
+
+	c.cfg.Logger.WithCtxValues(ctx).WithValues(log.Kv{"variant": variant}).Debugf("Validating with %q canary variant", variant)
+	return v.Validate(ctx, ar, obj)
+}
@@ -0,0 +1,69 @@
+package validating_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestCanaryValidator(t *testing.T) {
+	stable := getMessageValidator("stable")
+	canary := validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+		return &validating.ValidatorResult{Valid: false, Message: "canary"}, nil
+	})
+
+	tests := map[string]struct {
+		selector   webhook.CanarySelector
+		review     model.AdmissionReview
+		expMessage string
+		expMetric  webhook.MeasureCanaryOpData
+	}{
+		"Not selected requests should use the stable validator.": {
+			selector:   webhook.CanarySelector{Namespaces: []string{"canary-ns"}},
+			review:     model.AdmissionReview{Namespace: "test-ns", Name: "test"},
+			expMessage: "stable",
+			expMetric:  webhook.MeasureCanaryOpData{CanaryName: "test", WebhookType: "validating", Variant: "stable", Success: true, Allowed: true},
+		},
+
+		"Selected requests should use the canary validator.": {
+			selector:   webhook.CanarySelector{Namespaces: []string{"canary-ns"}},
+			review:     model.AdmissionReview{Namespace: "canary-ns", Name: "test"},
+			expMessage: "canary",
+			expMetric:  webhook.MeasureCanaryOpData{CanaryName: "test", WebhookType: "validating", Variant: "canary", Success: true, Allowed: false},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			mrec.On("MeasureCanaryOp", mock.Anything, test.expMetric).Once().Return()
+
+			v, err := validating.NewCanaryValidator(validating.CanaryValidatorConfig{
+				Name:            "test",
+				Stable:          stable,
+				Canary:          canary,
+				Selector:        test.selector,
+				MetricsRecorder: mrec,
+			})
+			require.NoError(err)
+
+			gotRes, err := v.Validate(context.TODO(), &test.review, &corev1.Pod{})
+			if assert.NoError(err) {
+				assert.Equal(test.expMessage, gotRes.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package validating
+
+import (
+	"errors"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// DependencyErrorResult maps the errors of the dependency calls (`webhook.Dependency`) to the validator
+// result, using the verdict of the circuit open errors:
+//
+//   - Allow: Valid result with a warning.
+//   - Deny: Not valid result with a `503` (Service Unavailable) status.
+//   - Error and the rest of the errors: The error is returned.
+//
+// It can be used directly as the return of the validators (e.g: `return validating.DependencyErrorResult(err)`).
+func DependencyErrorResult(err error) (*ValidatorResult, error) {
+	var coErr *webhook.CircuitOpenError
+	if !errors.As(err, &coErr) {
+		return nil, err
+	}
+
+	switch coErr.Verdict {
+	case webhook.CircuitOpenVerdictAllow:
+		return &ValidatorResult{
+			Valid:    true,
+			Warnings: []string{coErr.Error()},
+		}, nil
+	case webhook.CircuitOpenVerdictDeny:
+		return &ValidatorResult{
+			Valid:   false,
+			Message: coErr.Error(),
+			Code:    http.StatusServiceUnavailable,
+			Reason:  metav1.StatusReasonServiceUnavailable,
+		}, nil
+	}
+
+	return nil, err
+}
@@ -0,0 +1,64 @@
+package validating_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+)
+
+func TestDependencyErrorResult(t *testing.T) {
+	tests := map[string]struct {
+		err       error
+		expResult *validating.ValidatorResult
+		expErr    bool
+	}{
+		"A regular error should be returned.": {
+			err:    errors.New("wanted"),
+			expErr: true,
+		},
+
+		"A circuit open error with error verdict should be returned.": {
+			err:    &webhook.CircuitOpenError{Dependency: "test", Verdict: webhook.CircuitOpenVerdictError},
+			expErr: true,
+		},
+
+		"A circuit open error with allow verdict should be valid with a warning.": {
+			err: fmt.Errorf("wrapped: %w", &webhook.CircuitOpenError{Dependency: "test", Verdict: webhook.CircuitOpenVerdictAllow}),
+			expResult: &validating.ValidatorResult{
+				Valid:    true,
+				Warnings: []string{`"test" dependency circuit breaker is open`},
+			},
+		},
+
+		"A circuit open error with deny verdict should not be valid.": {
+			err: &webhook.CircuitOpenError{Dependency: "test", Verdict: webhook.CircuitOpenVerdictDeny},
+			expResult: &validating.ValidatorResult{
+				Valid:   false,
+				Message: `"test" dependency circuit breaker is open`,
+				Code:    503,
+				Reason:  metav1.StatusReasonServiceUnavailable,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotRes, err := validating.DependencyErrorResult(test.err)
+
+			if test.expErr {
+				assert.ErrorIs(err, test.err)
+				assert.Nil(gotRes)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, gotRes)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package validating
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// EnforcementMode is the mode used by an enforcement validator on the not valid results.
+type EnforcementMode string
+
+const (
+	// EnforcementModeEnforce will deny the not valid resources.
+	EnforcementModeEnforce EnforcementMode = "enforce"
+	// EnforcementModeWarn will allow the not valid resources, returning the not valid message as a warning.
+	EnforcementModeWarn EnforcementMode = "warn"
+	// EnforcementModeAudit will allow the not valid resources silently, only logging and measuring them.
+	EnforcementModeAudit EnforcementMode = "audit"
+)
+
+func (m EnforcementMode) validate() error {
+	switch m {
+	case EnforcementModeEnforce, EnforcementModeWarn, EnforcementModeAudit:
+		return nil
+	}
+
+	return fmt.Errorf("unknown enforcement mode %q", m)
+}
+
+// The decisions of the enforcement validators.
+const (
+	enforcementDecisionAllowed = "allowed"
+	enforcementDecisionDenied  = "denied"
+	enforcementDecisionWarned  = "warned"
+	enforcementDecisionAudited = "audited"
+)
+
+// EnforcementValidatorConfig is the configuration of an enforcement validator.
+type EnforcementValidatorConfig struct {
+	// Name is the name of the validator (e.g the policy name), used on the logs and metrics.
+	Name string
+	// Validator is the wrapped validator.
+	Validator Validator
+	// Mode is the initial enforcement mode. By default enforce.
+	Mode EnforcementMode
+	// Logger is the logger.
+	Logger log.Logger
+	// MetricsRecorder is used to measure the enforcement decisions. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+func (c *EnforcementValidatorConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.Validator == nil {
+		return fmt.Errorf("validator is required")
+	}
+
+	if c.Mode == "" {
+		c.Mode = EnforcementModeEnforce
+	}
+	if err := c.Mode.validate(); err != nil {
+		return err
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"validator": c.Name})
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+// EnforcementValidator is a validator that wraps a validator and applies an enforcement mode to its
+// not valid results, this can be used to roll out new policies (e.g: audit -> warn -> enforce).
+// The mode can be changed at runtime. It satisfies Validator interface.
+type EnforcementValidator struct {
+	name       string
+	validator  Validator
+	logger     log.Logger
+	metricsRec webhook.ValidatorEnforcementMetricsRecorder
+
+	mu   sync.RWMutex
+	mode EnforcementMode
+}
+
+// NewEnforcementValidator returns a new enforcement validator.
+func NewEnforcementValidator(cfg EnforcementValidatorConfig) (*EnforcementValidator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &EnforcementValidator{
+		name:       cfg.Name,
+		validator:  cfg.Validator,
+		logger:     cfg.Logger,
+		metricsRec: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+		mode:       cfg.Mode,
+	}, nil
+}
+
+// Mode returns the current enforcement mode.
+func (e *EnforcementValidator) Mode() EnforcementMode {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.mode
+}
+
+// SetMode changes the enforcement mode, safe to be used concurrently with the validations.
+func (e *EnforcementValidator) SetMode(mode EnforcementMode) error {
+	if err := mode.validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mode = mode
+	return nil
+}
+
+// Validate satisfies Validator interface.
+func (e *EnforcementValidator) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+	mode := e.Mode()
+
+	res, err := e.validator.Validate(ctx, ar, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		return nil, fmt.Errorf("validator result can't be `nil`")
+	}
+
+	decision := enforcementDecisionAllowed
+	switch {
+	case res.Valid:
+	case mode == EnforcementModeEnforce:
+		decision = enforcementDecisionDenied
+	case mode == EnforcementModeWarn:
+		decision = enforcementDecisionWarned
+		res = &ValidatorResult{
+			StopChain:        res.StopChain,
+			Valid:            true,
+			Warnings:         append(res.Warnings, notValidMessage(res)),
+			AuditAnnotations: res.AuditAnnotations,
+		}
+	case mode == EnforcementModeAudit:
+		decision = enforcementDecisionAudited
+		e.logger.WithCtxValues(ctx).Infof("Not valid resource allowed by audit enforcement mode: %s", notValidMessage(res))
+		res = &ValidatorResult{
+			StopChain:        res.StopChain,
+			Valid:            true,
+			Warnings:         res.Warnings,
+			AuditAnnotations: res.AuditAnnotations,
+		}
+	}
+
+	e.metricsRec.MeasureValidatorEnforcementOp(ctx, webhook.MeasureValidatorEnforcementOpData{
+		ValidatorName:   e.name,
+		EnforcementMode: string(mode),
+		Decision:        decision,
+	})
+
+	return res, nil
+}
+
+// notValidMessage returns the message of a not valid result, using the field errors if empty.
+func notValidMessage(res *ValidatorResult) string {
+	if res.Message == "" && len(res.FieldErrors) > 0 {
+		return res.FieldErrors.ToAggregate().Error()
+	}
+
+	return res.Message
+}
@@ -0,0 +1,143 @@
+package validating_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestEnforcementValidator(t *testing.T) {
+	notValidResult := func() *validating.ValidatorResult {
+		return &validating.ValidatorResult{Valid: false, Message: "not valid", Warnings: []string{"w1"}}
+	}
+
+	tests := map[string]struct {
+		mode        validating.EnforcementMode
+		result      func() *validating.ValidatorResult
+		expResult   *validating.ValidatorResult
+		expDecision string
+		expCfgErr   bool
+	}{
+		"Valid results should be allowed.": {
+			mode:        validating.EnforcementModeEnforce,
+			result:      func() *validating.ValidatorResult { return &validating.ValidatorResult{Valid: true} },
+			expResult:   &validating.ValidatorResult{Valid: true},
+			expDecision: "allowed",
+		},
+
+		"By default, not valid results should be denied.": {
+			result:      notValidResult,
+			expResult:   &validating.ValidatorResult{Valid: false, Message: "not valid", Warnings: []string{"w1"}},
+			expDecision: "denied",
+		},
+
+		"On warn mode, not valid results should be allowed with the message as a warning.": {
+			mode:        validating.EnforcementModeWarn,
+			result:      notValidResult,
+			expResult:   &validating.ValidatorResult{Valid: true, Warnings: []string{"w1", "not valid"}},
+			expDecision: "warned",
+		},
+
+		"On warn mode, not valid results with field errors should be allowed with the field errors as a warning.": {
+			mode: validating.EnforcementModeWarn,
+			result: func() *validating.ValidatorResult {
+				return &validating.ValidatorResult{Valid: false, FieldErrors: field.ErrorList{field.Required(field.NewPath("spec", "replicas"), "")}}
+			},
+			expResult:   &validating.ValidatorResult{Valid: true, Warnings: []string{"spec.replicas: Required value"}},
+			expDecision: "warned",
+		},
+
+		"On audit mode, not valid results should be allowed silently.": {
+			mode:        validating.EnforcementModeAudit,
+			result:      notValidResult,
+			expResult:   &validating.ValidatorResult{Valid: true, Warnings: []string{"w1"}},
+			expDecision: "audited",
+		},
+
+		"An invalid mode should fail the configuration.": {
+			mode:      "unknown",
+			result:    notValidResult,
+			expCfgErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			if !test.expCfgErr {
+				expMode := test.mode
+				if expMode == "" {
+					expMode = validating.EnforcementModeEnforce
+				}
+				mrec.On("MeasureValidatorEnforcementOp", mock.Anything, webhook.MeasureValidatorEnforcementOpData{
+					ValidatorName:   "test",
+					EnforcementMode: string(expMode),
+					Decision:        test.expDecision,
+				}).Once().Return()
+			}
+
+			v, err := validating.NewEnforcementValidator(validating.EnforcementValidatorConfig{
+				Name: "test",
+				Mode: test.mode,
+				Validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+					return test.result(), nil
+				}),
+				MetricsRecorder: mrec,
+			})
+			if test.expCfgErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			gotRes, err := v.Validate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+			if assert.NoError(err) {
+				assert.Equal(test.expResult, gotRes)
+			}
+		})
+	}
+}
+
+func TestEnforcementValidatorSetMode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	v, err := validating.NewEnforcementValidator(validating.EnforcementValidatorConfig{
+		Name: "test",
+		Mode: validating.EnforcementModeAudit,
+		Validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+			return &validating.ValidatorResult{Valid: false, Message: "not valid"}, nil
+		}),
+	})
+	require.NoError(err)
+
+	// Audit.
+	gotRes, err := v.Validate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+	require.NoError(err)
+	assert.True(gotRes.Valid)
+
+	// Enforce.
+	require.NoError(v.SetMode(validating.EnforcementModeEnforce))
+	assert.Equal(validating.EnforcementModeEnforce, v.Mode())
+	gotRes, err = v.Validate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+	require.NoError(err)
+	assert.False(gotRes.Valid)
+
+	// Invalid modes should not change the mode.
+	assert.Error(v.SetMode("unknown"))
+	assert.Equal(validating.EnforcementModeEnforce, v.Mode())
+}
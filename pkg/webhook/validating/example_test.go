@@ -74,3 +74,21 @@ func ExampleValidator_chainValidatingWebhook() {
 	})
 
 }
+
+// typedPodValidatingWebhook shows how you would create a typed pod validating webhook, without type
+// assertions.
+func ExampleNewTypedWebhook_typedPodValidatingWebhook() {
+	pv := validating.TypedValidatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*validating.ValidatorResult, error) {
+		if len(pod.Spec.Containers) == 0 {
+			return &validating.ValidatorResult{Valid: false, Message: "pod without containers"}, nil
+		}
+
+		return &validating.ValidatorResult{Valid: true}, nil
+	})
+
+	// Create webhook.
+	_, _ = validating.NewTypedWebhook(validating.TypedWebhookConfig[*corev1.Pod]{
+		ID:        "typedPodValidatingWebhook",
+		Validator: pv,
+	})
+}
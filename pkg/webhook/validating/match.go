@@ -0,0 +1,83 @@
+package validating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+)
+
+// MatchValidatorConfig is the configuration of a match validator.
+type MatchValidatorConfig struct {
+	// Matcher is the matcher used to check the requests (e.g: `webhook.NewMatcher`).
+	Matcher webhook.Matcher
+	// Validator is the validator executed on the matched requests.
+	Validator Validator
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+}
+
+func (c *MatchValidatorConfig) defaults() error {
+	if c.Matcher == nil {
+		return fmt.Errorf("matcher is required")
+	}
+
+	if c.Validator == nil {
+		return fmt.Errorf("validator is required")
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	return nil
+}
+
+type matchValidator struct {
+	matcher   webhook.Matcher
+	validator Validator
+	logger    log.Logger
+	tracer    tracing.Tracer
+}
+
+// NewMatchValidator returns a validator that will only execute the wrapped validator on the requests
+// that match, the rest of the requests will be valid.
+func NewMatchValidator(cfg MatchValidatorConfig) (Validator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return matchValidator{
+		matcher:   cfg.Matcher,
+		validator: cfg.Validator,
+		logger:    cfg.Logger,
+		tracer:    cfg.Tracer,
+	}, nil
+}
+
+func (m matchValidator) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+	match, err := m.matcher.Match(ctx, ar, obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not match the request: %w", err)
+	}
+
+	if !match {
+		m.logger.WithCtxValues(ctx).Debugf("Request not matched, validator skipped")
+		m.tracer.AddTraceEvent(ctx, "validator skipped", map[string]interface{}{"reason": "request not matched"})
+		return &ValidatorResult{Valid: true}, nil
+	}
+
+	return m.validator.Validate(ctx, ar, obj)
+}
@@ -0,0 +1,54 @@
+package validating_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+)
+
+func TestMatchValidator(t *testing.T) {
+	tests := map[string]struct {
+		match     bool
+		expResult *validating.ValidatorResult
+	}{
+		"Matched requests should be validated.": {
+			match:     true,
+			expResult: &validating.ValidatorResult{Valid: false, Message: "invalid"},
+		},
+
+		"Not matched requests should skip the validation and be valid.": {
+			match:     false,
+			expResult: &validating.ValidatorResult{Valid: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			v, err := validating.NewMatchValidator(validating.MatchValidatorConfig{
+				Matcher: webhook.MatcherFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (bool, error) {
+					return test.match, nil
+				}),
+				Validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+					return &validating.ValidatorResult{Valid: false, Message: "invalid"}, nil
+				}),
+			})
+			require.NoError(err)
+
+			gotRes, err := v.Validate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+			if assert.NoError(err) {
+				assert.Equal(test.expResult, gotRes)
+			}
+		})
+	}
+}
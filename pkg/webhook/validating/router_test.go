@@ -0,0 +1,96 @@
+package validating_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+)
+
+func getMessageValidator(msg string) validating.Validator {
+	return validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+		return &validating.ValidatorResult{Valid: true, Message: msg}, nil
+	})
+}
+
+func TestKindRouter(t *testing.T) {
+	podsGVR := &metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	podGVK := &metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}
+	deployGVK := &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	deployV1beta1GVK := &metav1.GroupVersionKind{Group: "apps", Version: "v1beta1", Kind: "Deployment"}
+
+	cfg := validating.KindRouterConfig{
+		Resources: map[webhook.RouteResource]validating.Validator{
+			{Version: "v1", Resource: "pods", SubResource: "status"}: getMessageValidator("pods-status"),
+		},
+		Kinds: map[metav1.GroupVersionKind]validating.Validator{
+			*podGVK:           getMessageValidator("pod"),
+			*deployV1beta1GVK: getMessageValidator("deployment-v1beta1"),
+		},
+	}
+
+	tests := map[string]struct {
+		fallback   validating.Validator
+		review     model.AdmissionReview
+		expMessage string
+		expErr     bool
+	}{
+		"A request should be routed by its kind.": {
+			review:     model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: podGVK},
+			expMessage: "pod",
+		},
+
+		"A request should be routed by its resource and subresource.": {
+			review:     model.AdmissionReview{RequestGVR: podsGVR, RequestGVK: podGVK, RequestSubResource: "status"},
+			expMessage: "pods-status",
+		},
+
+		"A converted request should be routed by its converted kind.": {
+			fallback:   getMessageValidator("fallback"),
+			review:     model.AdmissionReview{GVK: deployGVK, RequestGVK: deployV1beta1GVK},
+			expMessage: "fallback",
+		},
+
+		"A converted request should be routed by its converted resource and subresource.": {
+			review:     model.AdmissionReview{GVR: podsGVR, GVK: podGVK, SubResource: "status", RequestGVK: deployV1beta1GVK},
+			expMessage: "pods-status",
+		},
+
+		"A not matched request should be routed to the fallback.": {
+			fallback:   getMessageValidator("fallback"),
+			review:     model.AdmissionReview{RequestGVK: deployGVK},
+			expMessage: "fallback",
+		},
+
+		"A not matched request without fallback should fail.": {
+			review: model.AdmissionReview{RequestGVK: deployGVK},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			cfg := cfg
+			cfg.Fallback = test.fallback
+			router, err := validating.NewKindRouter(cfg)
+			require.NoError(err)
+
+			gotRes, err := router.Validate(context.TODO(), &test.review, &corev1.Pod{})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expMessage, gotRes.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package validating
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/tracing"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/internal/helpers"
+)
+
+// TimeoutValidatorConfig is the configuration of a timeout validator.
+type TimeoutValidatorConfig struct {
+	// Name is the name of the validator, used on the logs, traces and metrics.
+	Name string
+	// Validator is the wrapped validator.
+	Validator Validator
+	// Timeout is the maximum duration of the validator.
+	Timeout time.Duration
+	// TimeoutPolicy is the policy applied when the validator times out. By default error.
+	TimeoutPolicy webhook.StepTimeoutPolicy
+	// Logger is the logger.
+	Logger log.Logger
+	// Tracer is the tracer.
+	Tracer tracing.Tracer
+	// MetricsRecorder is used to measure the timeouts. By default no-op.
+	MetricsRecorder webhook.MetricsRecorder
+}
+
+func (c *TimeoutValidatorConfig) defaults() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if c.Validator == nil {
+		return fmt.Errorf("validator is required")
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+
+	if c.TimeoutPolicy == "" {
+		c.TimeoutPolicy = webhook.StepTimeoutPolicyError
+	}
+	if err := c.TimeoutPolicy.Validate(); err != nil {
+		return err
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+	c.Logger = c.Logger.WithValues(log.Kv{"validator": c.Name})
+
+	if c.Tracer == nil {
+		c.Tracer = tracing.Noop
+	}
+
+	if c.MetricsRecorder == nil {
+		c.MetricsRecorder = webhook.NoopMetricsRecorder
+	}
+
+	return nil
+}
+
+type timeoutValidator struct {
+	cfg        TimeoutValidatorConfig
+	metricsRec webhook.StepTimeoutMetricsRecorder
+}
+
+// NewTimeoutValidator returns a validator that limits the duration of the wrapped validator, applying
+// the timeout policy when reached. This can be used to set per validator timeouts on chains.
+func NewTimeoutValidator(cfg TimeoutValidatorConfig) (Validator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return timeoutValidator{
+		cfg:        cfg,
+		metricsRec: webhook.NewFullMetricsRecorder(cfg.MetricsRecorder),
+	}, nil
+}
+
+func (t timeoutValidator) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+	res, timedOut, err := helpers.RunWithTimeout(ctx, t.cfg.Timeout, func(ctx context.Context) (*ValidatorResult, error) {
+		return t.cfg.Validator.Validate(ctx, ar, obj)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !timedOut {
+		return res, nil
+	}
+
+	// Timeout.
+	policy := t.cfg.TimeoutPolicy
+	msg := webhook.StepTimeoutMessage(t.cfg.Name, t.cfg.Timeout)
+	t.cfg.Logger.WithCtxValues(ctx).WithValues(log.Kv{"timeout-policy": policy}).Warningf("Validator timed out: %s", msg)
+	t.cfg.Tracer.AddTraceEvent(ctx, "step timeout", map[string]interface{}{
+		"step":           t.cfg.Name,
+		"timeout":        t.cfg.Timeout.String(),
+		"timeout_policy": policy,
+	})
+	t.metricsRec.MeasureStepTimeoutOp(ctx, webhook.MeasureStepTimeoutOpData{
+		StepName:      t.cfg.Name,
+		WebhookType:   model.WebhookKindValidating,
+		TimeoutPolicy: string(policy),
+	})
+
+	switch policy {
+	case webhook.StepTimeoutPolicySkip:
+		return &ValidatorResult{Valid: true}, nil
+	case webhook.StepTimeoutPolicyDeny:
+		return &ValidatorResult{
+			Valid:   false,
+			Message: msg,
+			Code:    http.StatusGatewayTimeout,
+			Reason:  metav1.StatusReasonTimeout,
+		}, nil
+	default:
+		return nil, webhook.NewStepTimeoutError(t.cfg.Name, t.cfg.Timeout)
+	}
+}
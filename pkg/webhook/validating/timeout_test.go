@@ -0,0 +1,89 @@
+package validating_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/webhookmock"
+)
+
+func TestTimeoutValidator(t *testing.T) {
+	slowValidator := validating.ValidatorFunc(func(ctx context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+		<-ctx.Done()
+		return &validating.ValidatorResult{Valid: false, Message: "slow"}, nil
+	})
+
+	tests := map[string]struct {
+		cfg        validating.TimeoutValidatorConfig
+		expTimeout bool
+		expResult  *validating.ValidatorResult
+		expErr     bool
+	}{
+		"A validator that finishes on time should validate.": {
+			cfg:       validating.TimeoutValidatorConfig{Validator: getMessageValidator("fast"), Timeout: time.Second},
+			expResult: &validating.ValidatorResult{Valid: true, Message: "fast"},
+		},
+
+		"A validator that times out with skip policy should be valid.": {
+			cfg:        validating.TimeoutValidatorConfig{Validator: slowValidator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicySkip},
+			expTimeout: true,
+			expResult:  &validating.ValidatorResult{Valid: true},
+		},
+
+		"A validator that times out with deny policy should not be valid.": {
+			cfg:        validating.TimeoutValidatorConfig{Validator: slowValidator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicyDeny},
+			expTimeout: true,
+			expResult: &validating.ValidatorResult{
+				Valid:   false,
+				Message: `"test" step timed out after 10ms`,
+				Code:    504,
+				Reason:  metav1.StatusReasonTimeout,
+			},
+		},
+
+		"A validator that times out with error policy should fail.": {
+			cfg:        validating.TimeoutValidatorConfig{Validator: slowValidator, Timeout: 10 * time.Millisecond, TimeoutPolicy: webhook.StepTimeoutPolicyError},
+			expTimeout: true,
+			expErr:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mrec := webhookmock.NewFullMetricsRecorder(t)
+			if test.expTimeout {
+				mrec.On("MeasureStepTimeoutOp", mock.Anything, webhook.MeasureStepTimeoutOpData{
+					StepName:      "test",
+					WebhookType:   "validating",
+					TimeoutPolicy: string(test.cfg.TimeoutPolicy),
+				}).Once().Return()
+			}
+
+			test.cfg.Name = "test"
+			test.cfg.MetricsRecorder = mrec
+			v, err := validating.NewTimeoutValidator(test.cfg)
+			require.NoError(err)
+
+			gotRes, err := v.Validate(context.TODO(), &model.AdmissionReview{}, &corev1.Pod{})
+			if test.expErr {
+				assert.ErrorIs(err, webhook.ErrStepTimeout)
+				assert.EqualError(err, `"test" step timed out after 10ms`)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, gotRes)
+			}
+		})
+	}
+}
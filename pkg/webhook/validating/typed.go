@@ -0,0 +1,104 @@
+package validating
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/internal/helpers"
+)
+
+// TypedValidator is the same as a Validator but it receives the object already typed
+// (e.g: `*corev1.Pod`), so the user doesn't need to type assert it.
+//
+// On update operations, the typed old object is available using `webhook.TypedOldObjectFromCtx`.
+type TypedValidator[T metav1.Object] interface {
+	Validate(ctx context.Context, ar *model.AdmissionReview, obj T) (result *ValidatorResult, err error)
+}
+
+// TypedValidatorFunc is a helper type to create typed validators from functions.
+type TypedValidatorFunc[T metav1.Object] func(context.Context, *model.AdmissionReview, T) (*ValidatorResult, error)
+
+// Validate satisfies TypedValidator interface.
+func (f TypedValidatorFunc[T]) Validate(ctx context.Context, ar *model.AdmissionReview, obj T) (*ValidatorResult, error) {
+	return f(ctx, ar, obj)
+}
+
+// NewTypedChain returns a new typed chain of validators, it has the same behavior
+// as the regular Chain.
+func NewTypedChain[T metav1.Object](logger log.Logger, validators ...TypedValidator[T]) TypedValidator[T] {
+	vs := make([]Validator, 0, len(validators))
+	for _, v := range validators {
+		vs = append(vs, FromTyped(v))
+	}
+
+	return typedChain[T]{chain: NewChain(logger, vs...)}
+}
+
+type typedChain[T metav1.Object] struct {
+	chain Validator
+}
+
+func (c typedChain[T]) Validate(ctx context.Context, ar *model.AdmissionReview, obj T) (*ValidatorResult, error) {
+	return c.chain.Validate(ctx, ar, obj)
+}
+
+// FromTyped converts a typed validator into a regular Validator, this can be used to use typed validators
+// in regular chains or webhooks. If the received object is not of the validator type, it will return a
+// `webhook.InvalidObjectTypeError`.
+func FromTyped[T metav1.Object](v TypedValidator[T]) Validator {
+	return ValidatorFunc(func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+		tObj, ok := obj.(T)
+		if !ok {
+			var zero T
+			return nil, &webhook.InvalidObjectTypeError{Expected: fmt.Sprintf("%T", zero), Got: fmt.Sprintf("%T", obj)}
+		}
+
+		return v.Validate(ctx, ar, tObj)
+	})
+}
+
+// TypedWebhookConfig is the typed validating webhook configuration.
+type TypedWebhookConfig[T metav1.Object] struct {
+	// ID is the id of the webhook.
+	ID string
+	// Validator is the webhook typed validator.
+	Validator TypedValidator[T]
+	// Logger is the app logger.
+	Logger log.Logger
+}
+
+// NewTypedWebhook returns a validating webhook for a single type of resource, the type must be a
+// pointer to a Kubernetes object type (e.g: `*corev1.Pod`). It has the same behavior as the regular
+// webhook, but the validator will receive the object already typed.
+//
+// If the webhook receives a different kind of resource, the review will fail with a
+// `webhook.InvalidObjectTypeError`.
+func NewTypedWebhook[T metav1.Object](cfg TypedWebhookConfig[T]) (webhook.Webhook, error) {
+	obj, err := helpers.NewTypedObject[T]()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if cfg.Validator == nil {
+		return nil, fmt.Errorf("invalid configuration: validator is required")
+	}
+
+	validator := FromTyped(cfg.Validator)
+	return NewWebhook(WebhookConfig{
+		ID:     cfg.ID,
+		Obj:    obj,
+		Logger: cfg.Logger,
+		Validator: ValidatorFunc(func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+			if err := helpers.CheckObjectKind(obj, ar.GVK); err != nil {
+				return nil, err
+			}
+
+			return validator.Validate(ctx, ar, obj)
+		}),
+	})
+}
@@ -0,0 +1,134 @@
+package validating_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
+)
+
+func getDeploymentJSON() []byte {
+	bs, _ := json.Marshal(&appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "testDeployment", Namespace: "testNS"},
+	})
+	return bs
+}
+
+func getTypedNSValidator(ns string) validating.TypedValidator[*corev1.Pod] {
+	return validating.TypedValidatorFunc[*corev1.Pod](func(_ context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*validating.ValidatorResult, error) {
+		if pod.Namespace != ns {
+			return &validating.ValidatorResult{Valid: false, Message: "invalid namespace"}, nil
+		}
+		return &validating.ValidatorResult{Valid: true}, nil
+	})
+}
+
+func TestTypedWebhook(t *testing.T) {
+	tests := map[string]struct {
+		validator   validating.TypedValidator[*corev1.Pod]
+		review      model.AdmissionReview
+		expResponse model.AdmissionResponse
+		expTypedErr bool
+		expErr      bool
+	}{
+		"A typed webhook should give the typed object to the validator.": {
+			validator: getTypedNSValidator("testNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				GVK:          &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				NewObjectRaw: getPodJSON(),
+			},
+			expResponse: &model.ValidatingAdmissionResponse{ID: "test", Allowed: true},
+		},
+
+		"A typed webhook should give the typed old object on updates.": {
+			validator: validating.TypedValidatorFunc[*corev1.Pod](func(ctx context.Context, _ *model.AdmissionReview, pod *corev1.Pod) (*validating.ValidatorResult, error) {
+				oldPod, ok := webhook.TypedOldObjectFromCtx[*corev1.Pod](ctx)
+				if !ok {
+					return nil, assert.AnError
+				}
+				return &validating.ValidatorResult{Valid: oldPod.Namespace == pod.Namespace}, nil
+			}),
+			review: model.AdmissionReview{
+				ID:           "test",
+				Operation:    model.OperationUpdate,
+				NewObjectRaw: getPodJSON(),
+				OldObjectRaw: getPodJSON(),
+			},
+			expResponse: &model.ValidatingAdmissionResponse{ID: "test", Allowed: true},
+		},
+
+		"A typed webhook should support typed chains.": {
+			validator: validating.NewTypedChain[*corev1.Pod](log.Noop,
+				getTypedNSValidator("testNS"),
+				getTypedNSValidator("otherNS"),
+			),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getPodJSON(),
+			},
+			expResponse: &model.ValidatingAdmissionResponse{ID: "test", Allowed: false, Message: "invalid namespace"},
+		},
+
+		"A typed webhook that receives a wrong kind should fail with a typed error.": {
+			validator: getTypedNSValidator("testNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				GVK:          &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+				NewObjectRaw: getDeploymentJSON(),
+			},
+			expErr:      true,
+			expTypedErr: true,
+		},
+
+		"A typed webhook that receives a wrong kind object without admission review kind should fail with a typed error.": {
+			validator: getTypedNSValidator("testNS"),
+			review: model.AdmissionReview{
+				ID:           "test",
+				NewObjectRaw: getDeploymentJSON(),
+			},
+			expErr:      true,
+			expTypedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			wh, err := validating.NewTypedWebhook(validating.TypedWebhookConfig[*corev1.Pod]{ID: "test", Validator: test.validator})
+			require.NoError(err)
+
+			gotResponse, err := wh.Review(context.TODO(), test.review)
+
+			if test.expErr {
+				assert.Error(err)
+				var typedErr *webhook.InvalidObjectTypeError
+				assert.Equal(test.expTypedErr, errors.As(err, &typedErr))
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResponse, gotResponse)
+			}
+		})
+	}
+}
+
+func TestFromTyped(t *testing.T) {
+	v := validating.FromTyped(getTypedNSValidator("testNS"))
+
+	_, err := v.Validate(context.TODO(), &model.AdmissionReview{}, &appsv1.Deployment{})
+	var typedErr *webhook.InvalidObjectTypeError
+	assert.ErrorAs(t, err, &typedErr)
+}
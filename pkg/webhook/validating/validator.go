@@ -3,8 +3,11 @@ package validating
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/slok/kubewebhook/v2/pkg/log"
 	"github.com/slok/kubewebhook/v2/pkg/model"
@@ -17,9 +20,22 @@ type ValidatorResult struct {
 	// Valid tells the apiserver that the resource is correct and it should allow or not.
 	Valid bool
 	// Message will be used by the apiserver to give more information in case the resource is not valid.
+	// If empty and there are field errors, the field errors will be used as the message.
 	Message string
+	// FieldErrors are the field level errors that made the resource not valid, they will be returned
+	// as the status causes, like the Kubernetes API validation does.
+	FieldErrors field.ErrorList
+	// Code is the status code used in case the resource is not valid. By default 400 (Bad Request),
+	// or 422 (Unprocessable Entity) if there are field errors.
+	Code int32
+	// Reason is the status reason used in case the resource is not valid. By default empty,
+	// or `Invalid` if there are field errors.
+	Reason metav1.StatusReason
 	// Warnings are special messages that can be set to warn the user (e.g deprecation messages, almost invalid resources...).
 	Warnings []string
+	// AuditAnnotations will be added to the apiserver audit log of the request (e.g the reason of an allowed exception).
+	// Only supported on 'v1' admission reviews.
+	AuditAnnotations map[string]string
 }
 
 // Validator knows how to validate the received kubernetes object.
@@ -30,6 +46,7 @@ type Validator interface {
 	// information of the review.
 	// Validators can be grouped in chains, that's why we have a `StopChain` boolean
 	// in the result, to stop executing the validators chain.
+	// On update operations, the decoded old object is available using `webhook.OldObjectFromCtx`.
 	Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (result *ValidatorResult, err error)
 }
 
@@ -62,6 +79,7 @@ func NewChain(logger log.Logger, validators ...Validator) Validator {
 // Validate will execute all the validation chain.
 func (c chain) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
 	var warnings []string
+	var auditAnnotations map[string]string
 	for _, vl := range c.validators {
 		select {
 		case <-ctx.Done():
@@ -76,18 +94,322 @@ func (c chain) Validate(ctx context.Context, ar *model.AdmissionReview, obj meta
 				return nil, fmt.Errorf("validator result can't be `nil`")
 			}
 
-			// Don't lose the warnings through the chain.
+			// Don't lose the warnings and audit annotations through the chain.
 			warnings = append(warnings, res.Warnings...)
+			auditAnnotations = mergeAuditAnnotations(auditAnnotations, res.AuditAnnotations)
 
 			if res.StopChain || !res.Valid {
 				res.Warnings = warnings
+				res.AuditAnnotations = auditAnnotations
 				return res, nil
 			}
 		}
 	}
 
 	return &ValidatorResult{
-		Valid:    true,
-		Warnings: warnings,
+		Valid:            true,
+		Warnings:         warnings,
+		AuditAnnotations: auditAnnotations,
 	}, nil
 }
+
+// mergeAuditAnnotations merges the new audit annotations into the current ones, the new ones
+// have priority.
+func mergeAuditAnnotations(current, new map[string]string) map[string]string {
+	if len(new) == 0 {
+		return current
+	}
+
+	merged := make(map[string]string, len(current)+len(new))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range new {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+type collectAllChain struct {
+	validators []Validator
+	logger     log.Logger
+}
+
+// NewCollectAllChain returns a new chain of validators that instead of ending on the first
+// not valid result, will execute all the validators and merge all the not valid results into
+// a single one, so the user gets all the problems at once.
+// - If any of the validators returns an error, the chain will end.
+// - If any of the validators returns an stopChain == true, the chain will end.
+// - The messages of the not valid results will be merged (if empty, the field errors will be used).
+// - The field errors of the not valid results will be merged.
+// - The code and reason will be the first ones set by the not valid results.
+func NewCollectAllChain(logger log.Logger, validators ...Validator) Validator {
+	return collectAllChain{
+		validators: validators,
+		logger:     logger,
+	}
+}
+
+// Validate will execute all the validation chain.
+func (c collectAllChain) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+	agg := newResultAggregator()
+	for _, vl := range c.validators {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("validator chain not finished correctly, context done")
+		default:
+			res, err := vl.Validate(ctx, ar, obj)
+			if err != nil {
+				return nil, err
+			}
+
+			if res == nil {
+				return nil, fmt.Errorf("validator result can't be `nil`")
+			}
+
+			agg.add(res)
+			if res.StopChain {
+				return agg.result(true), nil
+			}
+		}
+	}
+
+	return agg.result(false), nil
+}
+
+// resultAggregator knows how to merge multiple validator results into one.
+type resultAggregator struct {
+	invalid          bool
+	messages         []string
+	fieldErrors      field.ErrorList
+	code             int32
+	reason           metav1.StatusReason
+	warnings         []string
+	auditAnnotations map[string]string
+}
+
+func newResultAggregator() *resultAggregator {
+	return &resultAggregator{}
+}
+
+func (r *resultAggregator) add(res *ValidatorResult) {
+	r.warnings = append(r.warnings, res.Warnings...)
+	r.auditAnnotations = mergeAuditAnnotations(r.auditAnnotations, res.AuditAnnotations)
+
+	// Valid results don't have any denial data.
+	if res.Valid {
+		return
+	}
+
+	r.invalid = true
+	r.fieldErrors = append(r.fieldErrors, res.FieldErrors...)
+	switch {
+	case res.Message != "":
+		r.messages = append(r.messages, res.Message)
+	case len(res.FieldErrors) > 0:
+		r.messages = append(r.messages, res.FieldErrors.ToAggregate().Error())
+	}
+	if r.code == 0 {
+		r.code = res.Code
+	}
+	if r.reason == "" {
+		r.reason = res.Reason
+	}
+}
+
+func (r *resultAggregator) result(stopChain bool) *ValidatorResult {
+	res := &ValidatorResult{
+		StopChain:        stopChain,
+		Valid:            !r.invalid,
+		Warnings:         r.warnings,
+		AuditAnnotations: r.auditAnnotations,
+	}
+	if r.invalid {
+		res.Message = strings.Join(r.messages, "; ")
+		res.FieldErrors = r.fieldErrors
+		res.Code = r.code
+		res.Reason = r.reason
+	}
+
+	return res
+}
+
+// ParallelChainConfig is the configuration of the parallel validator chain.
+type ParallelChainConfig struct {
+	// Validators are the validators of the chain, the order is used to aggregate the results.
+	Validators []Validator
+	// MaxConcurrency is the maximum number of validators executed at the same time, by default
+	// all the validators will be executed at the same time.
+	MaxConcurrency int
+	// Logger is the logger.
+	Logger log.Logger
+}
+
+func (c *ParallelChainConfig) defaults() error {
+	if c.MaxConcurrency < 0 {
+		return fmt.Errorf("max concurrency can't be negative")
+	}
+
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = len(c.Validators)
+	}
+
+	// We need at least one slot.
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = 1
+	}
+
+	if c.Logger == nil {
+		c.Logger = log.Noop
+	}
+
+	return nil
+}
+
+type parallelChain struct {
+	validators     []Validator
+	maxConcurrency int
+	logger         log.Logger
+}
+
+// NewParallelChain returns a new chain of validators that will execute the validators concurrently,
+// sharing the same context. The validators must not modify the received object.
+//
+// The result is the same as the regular chain would return executing the validators sequentially
+// in the same order, this makes the results deterministic:
+// - If any of the validators returns an error, the chain will end.
+// - If any of the validators returns an stopChain == true, the chain will end.
+// - If any of the validators returns as no valid, the chain will end.
+//
+// When the result is final (e.g: a not valid result and all the previous validators are valid), the
+// context of the validators that are still running will be canceled and the pending ones will not be
+// executed.
+func NewParallelChain(cfg ParallelChainConfig) (Validator, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return parallelChain{
+		validators:     cfg.Validators,
+		maxConcurrency: cfg.MaxConcurrency,
+		logger:         cfg.Logger,
+	}, nil
+}
+
+type parallelChainOutcome struct {
+	res  *ValidatorResult
+	err  error
+	done bool
+}
+
+// Validate will execute all the validation chain.
+func (c parallelChain) Validate(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*ValidatorResult, error) {
+	vCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		outcomes = make([]parallelChainOutcome, len(c.validators))
+		next     = 0  // The first validator that doesn't have the outcome yet.
+		final    = -1 // The validator that has the final outcome of the chain.
+		slots    = make(chan struct{}, c.maxConcurrency)
+	)
+
+	// setOutcome stores the outcome of a validator and checks, in order, if the
+	// chain outcome is final, if final, it will cancel the rest of the validators.
+	setOutcome := func(i int, o parallelChainOutcome) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		outcomes[i] = o
+		for final < 0 && next < len(outcomes) && outcomes[next].done {
+			o := outcomes[next]
+			if o.err != nil || o.res.StopChain || !o.res.Valid {
+				final = next
+				c.logger.WithCtxValues(ctx).Debugf("Validator chain outcome final on validator %d, canceling the rest", next)
+				cancel()
+				break
+			}
+			next++
+		}
+	}
+
+launchLoop:
+	for i, vl := range c.validators {
+		select {
+		case slots <- struct{}{}:
+		case <-vCtx.Done():
+			break launchLoop
+		}
+
+		// The outcome could be final while we were waiting for the slot.
+		if vCtx.Err() != nil {
+			<-slots
+			break launchLoop
+		}
+
+		wg.Add(1)
+		go func(i int, vl Validator) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			setOutcome(i, c.validate(vCtx, vl, ar, obj))
+		}(i, vl)
+	}
+	wg.Wait()
+
+	if final >= 0 {
+		o := outcomes[final]
+		if o.err != nil {
+			return nil, o.err
+		}
+
+		res := o.res
+		res.Warnings, res.AuditAnnotations = c.mergeData(outcomes[:final+1])
+		return res, nil
+	}
+
+	if next < len(outcomes) {
+		return nil, fmt.Errorf("validator chain not finished correctly, context done")
+	}
+
+	warnings, auditAnnotations := c.mergeData(outcomes)
+	return &ValidatorResult{
+		Valid:            true,
+		Warnings:         warnings,
+		AuditAnnotations: auditAnnotations,
+	}, nil
+}
+
+func (c parallelChain) validate(ctx context.Context, vl Validator, ar *model.AdmissionReview, obj metav1.Object) (o parallelChainOutcome) {
+	// Panics on goroutines can't be recovered by the webhook, handle them as errors.
+	defer func() {
+		if p := recover(); p != nil {
+			o = parallelChainOutcome{err: fmt.Errorf("validator panic: %v", p), done: true}
+		}
+	}()
+
+	// Don't execute if the context is done already.
+	if ctx.Err() != nil {
+		return parallelChainOutcome{err: fmt.Errorf("validator chain not finished correctly, context done"), done: true}
+	}
+
+	res, err := vl.Validate(ctx, ar, obj)
+	if err == nil && res == nil {
+		err = fmt.Errorf("validator result can't be `nil`")
+	}
+
+	return parallelChainOutcome{res: res, err: err, done: true}
+}
+
+// mergeData merges the warnings and audit annotations of the outcomes in order.
+func (c parallelChain) mergeData(outcomes []parallelChainOutcome) (warnings []string, auditAnnotations map[string]string) {
+	for _, o := range outcomes {
+		warnings = append(warnings, o.res.Warnings...)
+		auditAnnotations = mergeAuditAnnotations(auditAnnotations, o.res.AuditAnnotations)
+	}
+
+	return warnings, auditAnnotations
+}
@@ -3,12 +3,17 @@ package validating_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/slok/kubewebhook/v2/pkg/log"
+	"github.com/slok/kubewebhook/v2/pkg/model"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/validating/validatingmock"
 )
@@ -110,6 +115,33 @@ func TestValidatorChain(t *testing.T) {
 				Warnings:  []string{"w1", "w2", "w3", "w3.5"},
 			},
 		},
+
+		"Audit annotations should be merged through the chain, the latest validators have priority.": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2"}}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true}, nil)
+				m3.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, AuditAnnotations: map[string]string{"k2": "v2.5", "k3": "v3"}}, nil)
+				return []validating.Validator{m1, m2, m3}
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:            true,
+				AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2.5", "k3": "v3"},
+			},
+		},
+
+		"Audit annotations shouldn't be lost in the chain (stopped chain by invalid result).": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, AuditAnnotations: map[string]string{"k1": "v1"}}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: false, AuditAnnotations: map[string]string{"k2": "v2"}}, nil)
+				return []validating.Validator{m1, m2, m3}
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:            false,
+				AuditAnnotations: map[string]string{"k1": "v1", "k2": "v2"},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -138,3 +170,296 @@ func TestValidatorChain(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectAllValidatorChain(t *testing.T) {
+	tests := map[string]struct {
+		validatorMocks func() []validating.Validator
+		expResult      *validating.ValidatorResult
+		expErr         bool
+	}{
+		"Should call all the validators.": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, Warnings: []string{"w1"}}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true}, nil)
+				m3.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, Warnings: []string{"w3"}}, nil)
+				return []validating.Validator{m1, m2, m3}
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:    true,
+				Warnings: []string{"w1", "w3"},
+			},
+		},
+
+		"Should call all the validators and merge all the not valid results.": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3, m4 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: false, Message: "invalid 1", Warnings: []string{"w1"}}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: true, AuditAnnotations: map[string]string{"k2": "v2"}}, nil)
+				m3.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{
+					Valid:       false,
+					FieldErrors: field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), 0, "must be positive")},
+					Code:        422,
+					Reason:      metav1.StatusReasonInvalid,
+				}, nil)
+				m4.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{
+					Valid:       false,
+					Message:     "invalid 4",
+					FieldErrors: field.ErrorList{field.Required(field.NewPath("spec", "selector"), "")},
+					Code:        400,
+				}, nil)
+				return []validating.Validator{m1, m2, m3, m4}
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:   false,
+				Message: "invalid 1; spec.replicas: Invalid value: 0: must be positive; invalid 4",
+				FieldErrors: field.ErrorList{
+					field.Invalid(field.NewPath("spec", "replicas"), 0, "must be positive"),
+					field.Required(field.NewPath("spec", "selector"), ""),
+				},
+				Code:             422,
+				Reason:           metav1.StatusReasonInvalid,
+				Warnings:         []string{"w1"},
+				AuditAnnotations: map[string]string{"k2": "v2"},
+			},
+		},
+
+		"Should stop in the middle of the chain if any of the validators stops the chain, with the merged results.": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: false, Message: "invalid 1"}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: false, Message: "invalid 2", StopChain: true}, nil)
+				return []validating.Validator{m1, m2, m3}
+			},
+			expResult: &validating.ValidatorResult{
+				StopChain: true,
+				Valid:     false,
+				Message:   "invalid 1; invalid 2",
+			},
+		},
+
+		"In case of error the chain should be stopped.": {
+			validatorMocks: func() []validating.Validator {
+				m1, m2, m3 := &validatingmock.Validator{}, &validatingmock.Validator{}, &validatingmock.Validator{}
+				m1.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(&validating.ValidatorResult{Valid: false}, nil)
+				m2.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("wanted error"))
+				return []validating.Validator{m1, m2, m3}
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			validators := test.validatorMocks()
+
+			// Execute.
+			chain := validating.NewCollectAllChain(log.Noop, validators...)
+			res, err := chain.Validate(context.TODO(), nil, nil)
+
+			// Check results.
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expResult, res)
+			}
+
+			// Check validator calls.
+			for _, m := range validators {
+				mv := m.(*validatingmock.Validator)
+				mv.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestParallelValidatorChain(t *testing.T) {
+	// Helpers to create validators that finish in a specific order.
+	newValidator := func(wait time.Duration, res *validating.ValidatorResult, err error) validating.Validator {
+		return validating.ValidatorFunc(func(ctx context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+			time.Sleep(wait)
+			return res, err
+		})
+	}
+	newBlockedValidator := func() validating.Validator {
+		return validating.ValidatorFunc(func(ctx context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return &validating.ValidatorResult{Valid: true, Warnings: []string{"not canceled"}}, nil
+			}
+		})
+	}
+
+	tests := map[string]struct {
+		cfg       validating.ParallelChainConfig
+		expResult *validating.ValidatorResult
+		expErr    bool
+	}{
+		"Having all valid results, should aggregate them in order.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					newValidator(30*time.Millisecond, &validating.ValidatorResult{Valid: true, Warnings: []string{"w1"}, AuditAnnotations: map[string]string{"k": "v1"}}, nil),
+					newValidator(10*time.Millisecond, &validating.ValidatorResult{Valid: true, Warnings: []string{"w2"}, AuditAnnotations: map[string]string{"k": "v2"}}, nil),
+					newValidator(0, &validating.ValidatorResult{Valid: true, Warnings: []string{"w3"}}, nil),
+				},
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:            true,
+				Warnings:         []string{"w1", "w2", "w3"},
+				AuditAnnotations: map[string]string{"k": "v2"},
+			},
+		},
+
+		"Having a not valid result, should return it and cancel the rest of the validators.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					newValidator(20*time.Millisecond, &validating.ValidatorResult{Valid: true, Warnings: []string{"w1"}}, nil),
+					newValidator(0, &validating.ValidatorResult{Valid: false, Message: "invalid 2", Warnings: []string{"w2"}}, nil),
+					newBlockedValidator(),
+					newBlockedValidator(),
+				},
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:    false,
+				Message:  "invalid 2",
+				Warnings: []string{"w1", "w2"},
+			},
+		},
+
+		"Having multiple not valid results, should return the first one in order.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					newValidator(20*time.Millisecond, &validating.ValidatorResult{Valid: false, Message: "invalid 1"}, nil),
+					newValidator(0, &validating.ValidatorResult{Valid: false, Message: "invalid 2"}, nil),
+				},
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:   false,
+				Message: "invalid 1",
+			},
+		},
+
+		"Having a stop chain result, should return it and cancel the rest of the validators.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					newValidator(0, &validating.ValidatorResult{Valid: true, StopChain: true}, nil),
+					newBlockedValidator(),
+				},
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:     true,
+				StopChain: true,
+			},
+		},
+
+		"Having an error before a not valid result, should return the error.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					newValidator(20*time.Millisecond, nil, fmt.Errorf("wanted error")),
+					newValidator(0, &validating.ValidatorResult{Valid: false, Message: "invalid 2"}, nil),
+				},
+			},
+			expErr: true,
+		},
+
+		"Having a validator panic, should return an error.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{
+					validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+						panic("wanted panic")
+					}),
+				},
+			},
+			expErr: true,
+		},
+
+		"Having a nil result, should return an error.": {
+			cfg: validating.ParallelChainConfig{
+				Validators: []validating.Validator{newValidator(0, nil, nil)},
+			},
+			expErr: true,
+		},
+
+		"Having a concurrency limit, should execute all the validators.": {
+			cfg: validating.ParallelChainConfig{
+				MaxConcurrency: 1,
+				Validators: []validating.Validator{
+					newValidator(0, &validating.ValidatorResult{Valid: true, Warnings: []string{"w1"}}, nil),
+					newValidator(0, &validating.ValidatorResult{Valid: true, Warnings: []string{"w2"}}, nil),
+					newValidator(0, &validating.ValidatorResult{Valid: true, Warnings: []string{"w3"}}, nil),
+				},
+			},
+			expResult: &validating.ValidatorResult{
+				Valid:    true,
+				Warnings: []string{"w1", "w2", "w3"},
+			},
+		},
+
+		"Having an invalid max concurrency, should fail.": {
+			cfg:    validating.ParallelChainConfig{MaxConcurrency: -1},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			chain, err := validating.NewParallelChain(test.cfg)
+			if err == nil {
+				var res *validating.ValidatorResult
+				t0 := time.Now()
+				res, err = chain.Validate(context.TODO(), nil, nil)
+				assert.Less(time.Since(t0), 2*time.Second, "validators should be canceled")
+				if err == nil {
+					assert.Equal(test.expResult, res)
+				}
+			}
+
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestParallelValidatorChainConcurrencyLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	const maxConcurrency = 2
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	validator := validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return &validating.ValidatorResult{Valid: true}, nil
+	})
+
+	chain, err := validating.NewParallelChain(validating.ParallelChainConfig{
+		MaxConcurrency: maxConcurrency,
+		Validators:     []validating.Validator{validator, validator, validator, validator, validator, validator},
+	})
+	assert.NoError(err)
+
+	res, err := chain.Validate(context.TODO(), nil, nil)
+	assert.NoError(err)
+	assert.True(res.Valid)
+	assert.Equal(maxConcurrency, maxRunning)
+}
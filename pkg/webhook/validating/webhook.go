@@ -3,6 +3,8 @@ package validating
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"runtime/debug"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -80,7 +82,16 @@ func (w validatingWebhook) ID() string { return w.id }
 
 func (w validatingWebhook) Kind() model.WebhookKind { return model.WebhookKindValidating }
 
-func (w validatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (model.AdmissionResponse, error) {
+func (w validatingWebhook) Review(ctx context.Context, ar model.AdmissionReview) (resp model.AdmissionResponse, err error) {
+	// Don't let the user validators break the webhook with panics, handle them as an error.
+	defer func() {
+		if p := recover(); p != nil {
+			w.logger.WithCtxValues(ctx).Errorf("Validator panic recovered: %v\n%s", p, debug.Stack())
+			resp = nil
+			err = fmt.Errorf("validator panic: %v", p)
+		}
+	}()
+
 	// Delete operations don't have body because should be gone on the deletion, instead they have the body
 	// of the object we want to delete as an old object.
 	raw := ar.NewObjectRaw
@@ -100,6 +111,15 @@ func (w validatingWebhook) Review(ctx context.Context, ar model.AdmissionReview)
 		return nil, fmt.Errorf("impossible to type assert the deep copy to metav1.Object")
 	}
 
+	// On updates, give the decoded old object to the users so they can check the transitions.
+	if ar.Operation == model.OperationUpdate && len(ar.OldObjectRaw) > 0 {
+		oldObj, err := w.objectCreator.NewObject(ar.OldObjectRaw)
+		if err != nil {
+			return nil, fmt.Errorf("could not create old object from raw: %w", err)
+		}
+		ctx = webhook.CtxWithOldObject(ctx, oldObj)
+	}
+
 	res, err := w.validator.Validate(ctx, &ar, validatingObj)
 	if err != nil {
 		return nil, fmt.Errorf("validator error: %w", err)
@@ -112,10 +132,45 @@ func (w validatingWebhook) Review(ctx context.Context, ar model.AdmissionReview)
 	w.logger.WithCtxValues(ctx).WithValues(log.Kv{"valid": res.Valid}).Debugf("Webhook validating review finished with '%t' result", res.Valid)
 
 	// Forge response.
-	return &model.ValidatingAdmissionResponse{
-		ID:       ar.ID,
-		Allowed:  res.Valid,
-		Message:  res.Message,
-		Warnings: res.Warnings,
-	}, nil
+	vResp := &model.ValidatingAdmissionResponse{
+		ID:               ar.ID,
+		Allowed:          res.Valid,
+		Message:          res.Message,
+		Warnings:         res.Warnings,
+		AuditAnnotations: res.AuditAnnotations,
+	}
+	if !res.Valid {
+		setValidatingResponseStatus(vResp, res)
+	}
+
+	return vResp, nil
+}
+
+// setValidatingResponseStatus sets the status data of a not valid result on the response, mapping
+// the field errors into status causes in the same way Kubernetes API validation does.
+func setValidatingResponseStatus(resp *model.ValidatingAdmissionResponse, res *ValidatorResult) {
+	resp.Code = res.Code
+	resp.Reason = res.Reason
+	if len(res.FieldErrors) == 0 {
+		return
+	}
+
+	if resp.Message == "" {
+		resp.Message = res.FieldErrors.ToAggregate().Error()
+	}
+	if resp.Code == 0 {
+		resp.Code = http.StatusUnprocessableEntity
+	}
+	if resp.Reason == "" {
+		resp.Reason = metav1.StatusReasonInvalid
+	}
+
+	resp.Causes = make([]metav1.StatusCause, 0, len(res.FieldErrors))
+	for _, fErr := range res.FieldErrors {
+		resp.Causes = append(resp.Causes, metav1.StatusCause{
+			Type:    metav1.CauseType(fErr.Type),
+			Message: fErr.ErrorBody(),
+			Field:   fErr.Field,
+		})
+	}
 }
@@ -11,8 +11,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook"
 	"github.com/slok/kubewebhook/v2/pkg/webhook/validating"
 )
 
@@ -64,6 +66,15 @@ func TestPodAdmissionReviewValidation(t *testing.T) {
 			expErr: true,
 		},
 
+		"A webhook review with a validator panic should return an error.": {
+			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+				panic("wanted panic")
+			}),
+			review: model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()},
+			expErr: true,
+		},
+
 		"A static webhook review of a Pod with a valid validator result should return allowed.": {
 			cfg:       validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
 			validator: getFakeValidator(true, ""),
@@ -85,6 +96,74 @@ func TestPodAdmissionReviewValidation(t *testing.T) {
 			},
 		},
 
+		"A static webhook review of a Pod with a validator result with audit annotations should return the audit annotations.": {
+			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+				return &validating.ValidatorResult{Valid: true, AuditAnnotations: map[string]string{"reason": "allowed-exception"}}, nil
+			}),
+			review: model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()},
+			expResponse: &model.ValidatingAdmissionResponse{
+				ID:               "test",
+				Allowed:          true,
+				AuditAnnotations: map[string]string{"reason": "allowed-exception"},
+			},
+		},
+
+		"A static webhook review of a Pod with a invalid validator result with custom status should return not allowed with the status.": {
+			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+				return &validating.ValidatorResult{Valid: false, Message: "forbidden", Code: 403, Reason: metav1.StatusReasonForbidden}, nil
+			}),
+			review: model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()},
+			expResponse: &model.ValidatingAdmissionResponse{
+				ID:      "test",
+				Allowed: false,
+				Message: "forbidden",
+				Code:    403,
+				Reason:  metav1.StatusReasonForbidden,
+			},
+		},
+
+		"A static webhook review of a Pod with a invalid validator result with field errors should return not allowed with the causes.": {
+			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+				return &validating.ValidatorResult{
+					Valid: false,
+					FieldErrors: field.ErrorList{
+						field.Required(field.NewPath("metadata", "labels").Key("team"), "team label is required"),
+						field.Invalid(field.NewPath("spec", "replicas"), 0, "must be positive"),
+					},
+				}, nil
+			}),
+			review: model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()},
+			expResponse: &model.ValidatingAdmissionResponse{
+				ID:      "test",
+				Allowed: false,
+				Message: "[metadata.labels[team]: Required value: team label is required, spec.replicas: Invalid value: 0: must be positive]",
+				Code:    422,
+				Reason:  metav1.StatusReasonInvalid,
+				Causes: []metav1.StatusCause{
+					{Type: metav1.CauseTypeFieldValueRequired, Message: "Required value: team label is required", Field: "metadata.labels[team]"},
+					{Type: metav1.CauseTypeFieldValueInvalid, Message: "Invalid value: 0: must be positive", Field: "spec.replicas"},
+				},
+			},
+		},
+
+		"A static webhook review of a Pod with a valid validator result with field errors should ignore the field errors.": {
+			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, _ metav1.Object) (*validating.ValidatorResult, error) {
+				return &validating.ValidatorResult{
+					Valid:       true,
+					FieldErrors: field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), 0, "must be positive")},
+				}, nil
+			}),
+			review: model.AdmissionReview{ID: "test", NewObjectRaw: getPodJSON()},
+			expResponse: &model.ValidatingAdmissionResponse{
+				ID:      "test",
+				Allowed: true,
+			},
+		},
+
 		"A static webhook review of a delete operation on a Pod should allow.": {
 			cfg: validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
 			validator: validating.ValidatorFunc(func(_ context.Context, _ *model.AdmissionReview, obj metav1.Object) (*validating.ValidatorResult, error) {
@@ -228,3 +307,63 @@ func TestPodAdmissionReviewValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPodAdmissionReviewValidationOldObject(t *testing.T) {
+	tests := map[string]struct {
+		cfg       validating.WebhookConfig
+		review    model.AdmissionReview
+		expOldObj bool
+		expErr    bool
+	}{
+		"A static webhook review of an update operation should give the decoded old object.": {
+			cfg:       validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review:    model.AdmissionReview{ID: "test", Operation: model.OperationUpdate, NewObjectRaw: getPodJSON(), OldObjectRaw: getPodJSON()},
+			expOldObj: true,
+		},
+
+		"A dynamic webhook review of an update operation should give the decoded old object.": {
+			cfg:       validating.WebhookConfig{ID: "test"},
+			review:    model.AdmissionReview{ID: "test", Operation: model.OperationUpdate, NewObjectRaw: getPodJSON(), OldObjectRaw: getPodJSON()},
+			expOldObj: true,
+		},
+
+		"A webhook review of an update operation with an invalid old object should fail.": {
+			cfg:    validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review: model.AdmissionReview{ID: "test", Operation: model.OperationUpdate, NewObjectRaw: getPodJSON(), OldObjectRaw: []byte(`{`)},
+			expErr: true,
+		},
+
+		"A webhook review of a create operation shouldn't give the old object.": {
+			cfg:       validating.WebhookConfig{ID: "test", Obj: &corev1.Pod{}},
+			review:    model.AdmissionReview{ID: "test", Operation: model.OperationCreate, NewObjectRaw: getPodJSON()},
+			expOldObj: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var gotOldObj metav1.Object
+			var gotOK bool
+			test.cfg.Validator = validating.ValidatorFunc(func(ctx context.Context, _ *model.AdmissionReview, obj metav1.Object) (*validating.ValidatorResult, error) {
+				gotOldObj, gotOK = webhook.OldObjectFromCtx(ctx)
+				return &validating.ValidatorResult{Valid: true}, nil
+			})
+			wh, err := validating.NewWebhook(test.cfg)
+			assert.NoError(err)
+
+			_, err = wh.Review(context.TODO(), test.review)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expOldObj, gotOK)
+				if test.expOldObj {
+					assert.Equal("testPod", gotOldObj.GetName())
+					assert.Equal("value1", gotOldObj.GetLabels()["test1"])
+				}
+			}
+		})
+	}
+}
@@ -19,3 +19,4 @@ type Webhook interface {
 }
 
 //go:generate mockery --case underscore --output webhookmock --outpkg webhookmock --name Webhook
+//go:generate mockery --case underscore --output webhookmock --outpkg webhookmock --name MetricsRecorder